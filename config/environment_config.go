@@ -22,12 +22,9 @@ import (
 type Environments map[string]*Environment
 
 func (config Environments) inheritanceGraph() configInheritanceGraph {
-	g := make(configInheritanceGraph, len(config))
+	g := makeConfigInheritanceGraph(len(config))
 	for v := range config {
-		g[v] = make(map[string]interface{}, len(config[v].Inherits))
-		for _, w := range config[v].Inherits {
-			g[v][w] = true
-		}
+		g.add(v, config[v].Inherits)
 	}
 	return g
 }
@@ -98,6 +95,7 @@ func (config *EnvironmentUserConfig) Merge(other *EnvironmentUserConfig) {
 // Specifies the environment for template generation.
 type Environment struct {
 	BaseImportPath string                 // Base import path for templates
+	GoVersion      string                 // Go version for go.mod files (optional)
 	Inherits       []string               // Environments to inherit configs from
 	User           *EnvironmentUserConfig // User info for templates
 }
@@ -107,6 +105,9 @@ func (config *Environment) Merge(other *Environment) {
 	if other.BaseImportPath != "" {
 		config.BaseImportPath = other.BaseImportPath
 	}
+	if other.GoVersion != "" {
+		config.GoVersion = other.GoVersion
+	}
 	if other.User != nil {
 		if config.User == nil {
 			config.User = new(EnvironmentUserConfig)
@@ -15,12 +15,33 @@ import (
 	"errors"
 	"fmt"
 	"github.com/bmatsuo/go-validate"
+	"github.com/bmatsuo/gonew/templates"
+	"io"
 	"io/ioutil"
 	"os"
+	"path/filepath"
 	"strings"
 )
 
-type configInheritanceGraph map[string]map[string]interface{}
+// Maps each config name to the names it inherits, in declaration order.
+type configInheritanceGraph map[string][]string
+
+func makeConfigInheritanceGraph(n int) configInheritanceGraph {
+	return make(configInheritanceGraph, n)
+}
+
+// Add an edge v -> w for each w in inherits, ignoring repeated names.
+func (g configInheritanceGraph) add(v string, inherits []string) {
+	edges := make([]string, 0, len(inherits))
+	seen := make(map[string]bool, len(inherits))
+	for _, w := range inherits {
+		if !seen[w] {
+			seen[w] = true
+			edges = append(edges, w)
+		}
+	}
+	g[v] = edges
+}
 
 type configInheritanceDFSLog map[string]*struct{ start, finish int }
 
@@ -41,12 +62,16 @@ func (g configInheritanceGraph) HasCycles(start string) (bool, []string) {
 	dfs.visit(1, start)
 	mergeOrder := make([]string, 0, len(dfs.finished))
 	mergeOrder = append(mergeOrder, dfs.finished...)
-	//for i := len(dfs.finished)-1; i >= 0; i-- {
-	//	mergeOrder = append(mergeOrder, dfs.finished[i])
-	//}
 	return b, mergeOrder
 }
 
+// The depth-first, left-to-right postorder of start and its ancestors. See
+// Gonew.EnvironmentMergeOrder.
+func (g configInheritanceGraph) MergeOrder(start string) []string {
+	_, order := g.HasCycles(start)
+	return order
+}
+
 func (log configInheritanceDFSLog) State(vertex string) string {
 	switch vlog, ok := log[vertex]; {
 	case !ok:
@@ -78,7 +103,7 @@ func (dfs *configInheritanceDFS) visit(t int, v string) int {
 		dfs.onStart(v)
 	}
 
-	for w := range dfs.g[v] {
+	for _, w := range dfs.g[v] {
 		if dfs.log[w].start == 0 {
 			if dfs.onTree != nil {
 				dfs.onTree(v, w)
@@ -106,10 +131,32 @@ func (dfs *configInheritanceDFS) visit(t int, v string) int {
 	return t
 }
 
+// A directory of templates, given as its absolute path or as "name=path" to
+// name its template namespace.
 type ExternalTemplate string
 
+// The namespace of the templates: the given name, or else the base name of
+// the directory.
+func (config ExternalTemplate) Namespace() string {
+	if i := strings.Index(string(config), "="); i >= 0 && !strings.HasPrefix(string(config), "/") {
+		return string(config)[:i]
+	}
+	return filepath.Base(string(config))
+}
+
+// The directory holding the templates.
+func (config ExternalTemplate) Dir() string {
+	if i := strings.Index(string(config), "="); i >= 0 && !strings.HasPrefix(string(config), "/") {
+		return string(config)[i+1:]
+	}
+	return string(config)
+}
+
 func (config ExternalTemplate) Validate() (err error) {
-	path := string(config)
+	if ns := config.Namespace(); ns == "" || strings.ContainsAny(ns, `/\`) {
+		return errors.New("invalid namespace " + ns)
+	}
+	path := config.Dir()
 	var info os.FileInfo
 	if !strings.HasPrefix(path, "/") {
 		return errors.New("relative path " + path)
@@ -128,11 +175,13 @@ type Gonew struct {
 	Projects          Projects
 }
 
+// The named environment merged with the environments it inherits. See
+// EnvironmentMergeOrder for the order in which they are merged.
 func (config Gonew) Environment(name string) (*Environment, error) {
-	if _, present := config.Environments[name]; !present {
-		return nil, errors.New("unknown environment: " + name)
+	mergeOrder, err := config.EnvironmentMergeOrder(name)
+	if err != nil {
+		return nil, err
 	}
-	_, mergeOrder := config.Environments.inheritanceGraph().HasCycles(name)
 
 	env := new(Environment)
 	for _, key := range mergeOrder {
@@ -141,11 +190,26 @@ func (config Gonew) Environment(name string) (*Environment, error) {
 	return env, nil
 }
 
+// The named environment and its ancestors in the order they are merged. The
+// order is a depth-first, left-to-right traversal of Inherits; a later
+// environment overrides values from earlier ones. So an environment overrides
+// everything it inherits, and of two parents setting the same value the one
+// listed last in Inherits wins. An environment inherited along more than one
+// path is merged once, before any environment inheriting it.
+func (config Gonew) EnvironmentMergeOrder(name string) ([]string, error) {
+	if _, present := config.Environments[name]; !present {
+		return nil, errors.New("unknown environment: " + name)
+	}
+	return config.Environments.inheritanceGraph().MergeOrder(name), nil
+}
+
+// The named project merged with the projects it inherits. See
+// ProjectMergeOrder for the order in which they are merged.
 func (config Gonew) Project(name string) (*Project, error) {
-	if _, present := config.Projects[name]; !present {
-		return nil, errors.New("unknown project: " + name)
+	mergeOrder, err := config.ProjectMergeOrder(name)
+	if err != nil {
+		return nil, err
 	}
-	_, mergeOrder := config.Projects.inheritanceGraph().HasCycles(name)
 
 	env := new(Project)
 	for _, key := range mergeOrder {
@@ -154,6 +218,32 @@ func (config Gonew) Project(name string) (*Project, error) {
 	return env, nil
 }
 
+// Like Project, but also reports the project that contributed each value of
+// the merged project.
+func (config Gonew) ProjectOrigins(name string) (*Project, *ProjectOrigins, error) {
+	mergeOrder, err := config.ProjectMergeOrder(name)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	proj := new(Project)
+	origins := new(ProjectOrigins)
+	for _, key := range mergeOrder {
+		proj.Merge(config.Projects[key])
+		origins.merge(key, config.Projects[key])
+	}
+	return proj, origins, nil
+}
+
+// The named project and its ancestors in the order they are merged. It follows
+// the same rules as EnvironmentMergeOrder.
+func (config Gonew) ProjectMergeOrder(name string) ([]string, error) {
+	if _, present := config.Projects[name]; !present {
+		return nil, errors.New("unknown project: " + name)
+	}
+	return config.Projects.inheritanceGraph().MergeOrder(name), nil
+}
+
 func (config Gonew) Validate() (err error) {
 	err = validate.PropertyFunc("Environments", func() (err error) {
 		if config.Environments == nil {
@@ -172,10 +262,16 @@ func (config Gonew) Validate() (err error) {
 	}
 
 	err = validate.PropertyFunc("ExternalTemplates", func() (err error) {
+		namespaces := make(map[string]bool, len(config.ExternalTemplates))
 		for i, ext := range config.ExternalTemplates {
 			if err = validate.Index(i, ext); err != nil {
 				return
 			}
+			ns := ext.Namespace()
+			if namespaces[ns] || ns == templates.StandardNamespace {
+				return fmt.Errorf("[%d]: namespace %s is already used (name it with name=%s)", i, ns, ext.Dir())
+			}
+			namespaces[ns] = true
 		}
 		return
 	})
@@ -192,6 +288,9 @@ func (config Gonew) Validate() (err error) {
 	if err == nil {
 		err = validate.Property("Projects", config.Projects)
 	}
+	if err != nil {
+		return
+	}
 
 	err = validate.PropertyFunc("Default", func() (err error) {
 		err = validate.PropertyFunc("Environment", func() (err error) {
@@ -248,7 +347,13 @@ func (config *Gonew) UnmarshalFileJSON(filename string) error {
 		return err
 	}
 	defer f.Close()
-	p, err := ioutil.ReadAll(f)
+	return config.UnmarshalReaderJSON(f)
+}
+
+// Like UnmarshalFileJSON, but reads the configuration from r, like the
+// example configuration embedded in the gonew binary.
+func (config *Gonew) UnmarshalReaderJSON(r io.Reader) error {
+	p, err := ioutil.ReadAll(r)
 	if err != nil {
 		return err
 	}
@@ -0,0 +1,283 @@
+package config
+
+import (
+	"encoding/json"
+	"fmt"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func licenseProject(template string, inherits ...string) *Project {
+	return &Project{
+		Inherits: inherits,
+		Files: map[string]*ProjectFileConfig{
+			"License": {Path: "LICENSE", Type: "licenses", Templates: []string{template}},
+		},
+	}
+}
+
+func TestMergeOrderDiamond(t *testing.T) {
+	// base <- left, right <- leaf
+	conf := Gonew{Projects: Projects{
+		"base":  licenseProject("base.t2"),
+		"left":  {Inherits: []string{"base"}},
+		"right": licenseProject("right.t2", "base"),
+		"leaf":  {Inherits: []string{"left", "right"}},
+	}}
+	for i := 0; i < 20; i++ {
+		order, err := conf.ProjectMergeOrder("leaf")
+		if err != nil {
+			t.Fatal(err)
+		}
+		expect := []string{"base", "left", "right", "leaf"}
+		if !reflect.DeepEqual(order, expect) {
+			t.Fatalf("merge order %v (expected %v)", order, expect)
+		}
+	}
+
+	// right overrides base even though left (which does not set the
+	// license) is visited first.
+	proj, err := conf.Project("leaf")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if ts := proj.Files["License"].Templates; !reflect.DeepEqual(ts, []string{"right.t2"}) {
+		t.Errorf("unexpected license templates: %v", ts)
+	}
+}
+
+func TestMergeOrderConflict(t *testing.T) {
+	conf := Gonew{Projects: Projects{
+		"newbsd":   licenseProject("license.newbsd.t2"),
+		"mit":      licenseProject("license.mit.t2"),
+		"bsdfirst": {Inherits: []string{"newbsd", "mit"}},
+		"mitfirst": {Inherits: []string{"mit", "newbsd"}},
+		"override": licenseProject("license.other.t2", "newbsd", "mit"),
+		"repeated": {Inherits: []string{"newbsd", "mit", "newbsd"}},
+	}}
+	for name, expect := range map[string]string{
+		"bsdfirst": "license.mit.t2",
+		"mitfirst": "license.newbsd.t2",
+		"override": "license.other.t2",
+		"repeated": "license.mit.t2",
+	} {
+		// the result must not depend on map iteration order.
+		for i := 0; i < 20; i++ {
+			proj, err := conf.Project(name)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if ts := proj.Files["License"].Templates; !reflect.DeepEqual(ts, []string{expect}) {
+				t.Fatalf("%s: unexpected license templates: %v (expected %s)", name, ts, expect)
+			}
+		}
+	}
+}
+
+func TestMergeOrderDeep(t *testing.T) {
+	conf := Gonew{Environments: Environments{
+		"a": {BaseImportPath: "a", User: &EnvironmentUserConfig{Name: "a", Email: "a"}},
+		"b": {Inherits: []string{"a"}, User: &EnvironmentUserConfig{Email: "b"}},
+		"c": {Inherits: []string{"a"}, BaseImportPath: "c"},
+		"d": {Inherits: []string{"b", "c"}, GoVersion: "1.21"},
+		"e": {Inherits: []string{"c", "d"}},
+	}}
+	order, err := conf.EnvironmentMergeOrder("e")
+	if err != nil {
+		t.Fatal(err)
+	}
+	expect := []string{"a", "c", "b", "d", "e"}
+	if !reflect.DeepEqual(order, expect) {
+		t.Fatalf("merge order %v (expected %v)", order, expect)
+	}
+	env, err := conf.Environment("e")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if env.BaseImportPath != "c" || env.GoVersion != "1.21" || env.User.Name != "a" || env.User.Email != "b" {
+		t.Errorf("unexpected environment: %#v %#v", env, env.User)
+	}
+}
+
+func TestMergeHooks(t *testing.T) {
+	git := &HookConfig{Commands: []HookCommand{ShellCommand("git init")}}
+	gofmt := &HookConfig{Commands: []HookCommand{ArgsCommand("go", "fmt", "./...")}}
+	conf := Gonew{Projects: Projects{
+		"git": {Hooks: &ProjectHooksConfig{Post: make([]*HookConfig, 1, 10)}},
+		"cmd": {Inherits: []string{"git"}, Hooks: &ProjectHooksConfig{Post: []*HookConfig{gofmt}}},
+	}}
+	conf.Projects["git"].Hooks.Post[0] = git
+	for i := 0; i < 2; i++ {
+		proj, err := conf.Project("cmd")
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !reflect.DeepEqual(proj.Hooks.Post, []*HookConfig{gofmt, git}) {
+			t.Fatalf("unexpected post hooks: %v", proj.Hooks.Post)
+		}
+	}
+}
+
+func TestHookCommandJSON(t *testing.T) {
+	var hook HookConfig
+	p := []byte(`{"Cwd":"foo","Commands":["git init",["git","commit","-m","foo created"]]}`)
+	if err := json.Unmarshal(p, &hook); err != nil {
+		t.Fatal(err)
+	}
+	cmds := []HookCommand{ShellCommand("git init"), ArgsCommand("git", "commit", "-m", "foo created")}
+	if !reflect.DeepEqual(hook.Commands, cmds) {
+		t.Fatalf("unexpected commands: %#v", hook.Commands)
+	}
+	if s := hook.Commands[1].String(); s != `git commit -m "foo created"` {
+		t.Errorf("unexpected string: %s", s)
+	}
+	out, err := json.Marshal(hook.Commands)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(out) != `["git init",["git","commit","-m","foo created"]]` {
+		t.Errorf("unexpected json: %s", out)
+	}
+	for _, bad := range []string{`[]`, `{}`, `[1]`} {
+		var cmd HookCommand
+		if err := json.Unmarshal([]byte(bad), &cmd); err == nil {
+			t.Errorf("invalid command accepted: %s", bad)
+		}
+	}
+	hook.Env = map[string]string{"A=B": "c"}
+	if err := hook.Validate(); err == nil {
+		t.Errorf("invalid env name accepted")
+	}
+}
+
+func TestHookTimeout(t *testing.T) {
+	for timeout, ok := range map[string]bool{"": true, "30s": true, "1m30s": true, "0s": false, "-1s": false, "30": false} {
+		hook := &HookConfig{Timeout: timeout}
+		if err := hook.Validate(); (err == nil) != ok {
+			t.Errorf("%q: unexpected error: %v", timeout, err)
+		}
+	}
+}
+
+func TestExternalTemplates(t *testing.T) {
+	dir := t.TempDir()
+	for ext, ns := range map[ExternalTemplate]string{
+		ExternalTemplate(dir):           filepath.Base(dir),
+		ExternalTemplate("acme=" + dir): "acme",
+	} {
+		if ext.Namespace() != ns || ext.Dir() != dir {
+			t.Errorf("%s: unexpected namespace %q and dir %q", ext, ext.Namespace(), ext.Dir())
+		}
+	}
+	conf := Gonew{
+		Environments:      Environments{"default": {User: &EnvironmentUserConfig{Name: "n", Email: "e"}}},
+		Projects:          Projects{},
+		ExternalTemplates: []ExternalTemplate{ExternalTemplate(dir), ExternalTemplate("acme=" + dir)},
+	}
+	if err := conf.Validate(); err != nil {
+		t.Error(err)
+	}
+	conf.ExternalTemplates = append(conf.ExternalTemplates, ExternalTemplate("std="+dir))
+	if err := conf.Validate(); err == nil {
+		t.Errorf("std namespace accepted")
+	}
+	conf.ExternalTemplates[2] = ExternalTemplate(filepath.Join(dir, "..", filepath.Base(dir)))
+	if err := conf.Validate(); err == nil {
+		t.Errorf("duplicate namespace accepted")
+	}
+}
+
+func TestUnmarshalReaderJSON(t *testing.T) {
+	conf := new(Gonew)
+	err := conf.UnmarshalReaderJSON(strings.NewReader(`{
+		"Default": {"Environment": "default", "Project": "pkg"},
+		"Environments": {"default": {"User": {"Name": "n", "Email": "e"}}},
+		"Projects": {"pkg": {"Files": {"Main": {"Path": "{{.Project.Name}}.go", "Type": "go", "Templates": ["go.pkg.t2"]}}}}
+	}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if conf.Default.Project != "pkg" || conf.Projects["pkg"].Files["Main"].Type != "go" {
+		t.Errorf("unexpected config: %+v", conf)
+	}
+	if err := new(Gonew).UnmarshalReaderJSON(strings.NewReader(`{"Projects": `)); err == nil {
+		t.Errorf("truncated config accepted")
+	}
+}
+
+func TestValidateProjects(t *testing.T) {
+	const format = `{
+		"Environments": {"default": {"User": {"Name": "n", "Email": "e"}}},
+		"Projects": {"pkg": {%s}}
+	}`
+	for _, project := range []string{
+		`"Parameters": {"ratio": {"Type": "float"}}`,
+		`"Files": {"Pkg": {"Path": "a.go", "Type": "go", "Format": "bogus"}}`,
+		`"Files": {"Pkg": {"Path": "a.go", "Type": "go", "OnConflict": "explode"}}`,
+		`"Hooks": {"Post": [{"Timeout": "-5s", "Commands": ["true"]}]}`,
+	} {
+		conf := new(Gonew)
+		if err := conf.UnmarshalReaderJSON(strings.NewReader(fmt.Sprintf(format, project))); err == nil {
+			t.Errorf("invalid project accepted: %s", project)
+		}
+	}
+	conf := new(Gonew)
+	if err := conf.UnmarshalReaderJSON(strings.NewReader(fmt.Sprintf(format, `"Files": {"Pkg": {"Path": "a.go", "Type": "go", "Format": "gofmt"}}`))); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestHasCycles(t *testing.T) {
+	conf := Projects{
+		"a": {Inherits: []string{"b"}},
+		"b": {Inherits: []string{"c"}},
+		"c": {Inherits: []string{"a"}},
+		"d": {},
+	}
+	g := conf.inheritanceGraph()
+	if b, _ := g.HasCycles("a"); !b {
+		t.Errorf("cycle not detected")
+	}
+	if b, _ := g.HasCycles("d"); b {
+		t.Errorf("unexpected cycle")
+	}
+}
+
+func TestProjectOrigins(t *testing.T) {
+	git := &HookConfig{Commands: []HookCommand{ShellCommand("git init")}}
+	gofmt := &HookConfig{Commands: []HookCommand{ArgsCommand("go", "fmt", "./...")}}
+	conf := Gonew{Projects: Projects{
+		"git":    {Hooks: &ProjectHooksConfig{Post: []*HookConfig{git}}},
+		"newbsd": licenseProject("license.newbsd.t2"),
+		"cmd": {
+			Inherits: []string{"git", "newbsd"},
+			Hooks:    &ProjectHooksConfig{Post: []*HookConfig{gofmt}},
+			Files: map[string]*ProjectFileConfig{
+				"License": {Path: "COPYING"},
+				"Main":    {Path: "main.go", Type: "go", Templates: []string{"go.cmd.t2"}},
+			},
+		},
+	}}
+	chmod := &HookConfig{Commands: []HookCommand{ArgsCommand("chmod", "0644", "{{.File.Path}}")}}
+	conf.Projects["newbsd"].Files["License"].Hooks = []*HookConfig{chmod}
+	proj, origins, err := conf.ProjectOrigins("cmd")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(proj.Files["License"].Hooks, []*HookConfig{chmod}) {
+		t.Errorf("unexpected license hooks: %v", proj.Files["License"].Hooks)
+	}
+	license := ProjectFileOrigins{Path: "cmd", Type: "newbsd", Templates: "newbsd", Hooks: "newbsd"}
+	if *origins.Files["License"] != license {
+		t.Errorf("unexpected license origins: %+v", origins.Files["License"])
+	}
+	main := ProjectFileOrigins{Path: "cmd", Type: "cmd", Templates: "cmd"}
+	if *origins.Files["Main"] != main {
+		t.Errorf("unexpected main origins: %+v", origins.Files["Main"])
+	}
+	if !reflect.DeepEqual(origins.Post, []string{"cmd", "git"}) {
+		t.Errorf("unexpected post hook origins: %v", origins.Post)
+	}
+}
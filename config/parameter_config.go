@@ -0,0 +1,113 @@
+package config
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+
+	"github.com/bmatsuo/go-validate"
+)
+
+// Parameter types.
+const (
+	ParameterString = "string"
+	ParameterBool   = "bool"
+	ParameterInt    = "int"
+	ParameterChoice = "choice"
+)
+
+// A user supplied value for templates. Parameter values are given on the
+// command line or prompted for, and templates see them under .Vars.
+type ParameterConfig struct {
+	Type        string   // "string" (the default), "bool", "int" or "choice"
+	Default     string   // The value used when none is given (optional)
+	Description string   // Shown when prompting for a value (optional)
+	Pattern     string   // A regular expression values must match (optional)
+	Choices     []string // The values allowed for a "choice" parameter
+}
+
+func (config *ParameterConfig) Merge(other *ParameterConfig) {
+	if other.Type != "" {
+		config.Type = other.Type
+	}
+	if other.Default != "" {
+		config.Default = other.Default
+	}
+	if other.Description != "" {
+		config.Description = other.Description
+	}
+	if other.Pattern != "" {
+		config.Pattern = other.Pattern
+	}
+	if other.Choices != nil {
+		config.Choices = other.Choices
+	}
+}
+
+// Requires a known Type, Choices for "choice" parameters, a valid Pattern,
+// and a Default (if any) that is an acceptable value.
+func (config *ParameterConfig) Validate() (err error) {
+	err = validate.PropertyFunc("Type", func() error {
+		switch config.Type {
+		case "", ParameterString, ParameterBool, ParameterInt, ParameterChoice:
+			return nil
+		}
+		return fmt.Errorf("unknown type: %q", config.Type)
+	})
+	if err != nil {
+		return
+	}
+	err = validate.PropertyFunc("Choices", func() error {
+		if config.Type == ParameterChoice && len(config.Choices) == 0 {
+			return fmt.Errorf("missing")
+		}
+		return nil
+	})
+	if err != nil {
+		return
+	}
+	err = validate.PropertyFunc("Pattern", func() (err error) {
+		_, err = regexp.Compile(config.Pattern)
+		return
+	})
+	if err != nil {
+		return
+	}
+	err = validate.PropertyFunc("Default", func() (err error) {
+		if config.Default != "" {
+			_, err = config.Parse(config.Default)
+		}
+		return
+	})
+	return
+}
+
+// Convert s to a value of the parameter's type. A bool parameter produces a
+// bool, an int parameter an int and other parameters a string.
+func (config *ParameterConfig) Parse(s string) (interface{}, error) {
+	if config.Pattern != "" {
+		pattern, err := regexp.Compile(config.Pattern)
+		if err != nil {
+			return nil, err
+		}
+		if !pattern.MatchString(s) {
+			return nil, fmt.Errorf("%q does not match %q", s, config.Pattern)
+		}
+	}
+	switch config.Type {
+	case "", ParameterString:
+		return s, nil
+	case ParameterBool:
+		return strconv.ParseBool(s)
+	case ParameterInt:
+		return strconv.Atoi(s)
+	case ParameterChoice:
+		for _, choice := range config.Choices {
+			if s == choice {
+				return s, nil
+			}
+		}
+		return nil, fmt.Errorf("%q is not one of %q", s, config.Choices)
+	}
+	return nil, fmt.Errorf("unknown type: %q", config.Type)
+}
@@ -0,0 +1,50 @@
+package config
+
+import (
+	"testing"
+)
+
+func TestParameterParse(t *testing.T) {
+	for i, test := range []struct {
+		param ParameterConfig
+		input string
+		value interface{}
+		ok    bool
+	}{
+		{ParameterConfig{}, "abc", "abc", true},
+		{ParameterConfig{Type: "int"}, "8080", 8080, true},
+		{ParameterConfig{Type: "int"}, "http", nil, false},
+		{ParameterConfig{Type: "bool"}, "true", true, true},
+		{ParameterConfig{Type: "bool"}, "maybe", nil, false},
+		{ParameterConfig{Type: "choice", Choices: []string{"a", "b"}}, "b", "b", true},
+		{ParameterConfig{Type: "choice", Choices: []string{"a", "b"}}, "c", nil, false},
+		{ParameterConfig{Pattern: `^[a-z]+$`}, "abc", "abc", true},
+		{ParameterConfig{Pattern: `^[a-z]+$`}, "ABC", nil, false},
+	} {
+		v, err := test.param.Parse(test.input)
+		if test.ok != (err == nil) {
+			t.Errorf("test %d: unexpected error: %v", i, err)
+			continue
+		}
+		if test.ok && v != test.value {
+			t.Errorf("test %d: unexpected value: %#v", i, v)
+		}
+	}
+}
+
+func TestParameterValidate(t *testing.T) {
+	for i, test := range []struct {
+		param ParameterConfig
+		ok    bool
+	}{
+		{ParameterConfig{Type: "int", Default: "1"}, true},
+		{ParameterConfig{Type: "float"}, false},
+		{ParameterConfig{Type: "choice"}, false},
+		{ParameterConfig{Pattern: "("}, false},
+		{ParameterConfig{Type: "bool", Default: "yes please"}, false},
+	} {
+		if err := test.param.Validate(); test.ok != (err == nil) {
+			t.Errorf("test %d: unexpected error: %v", i, err)
+		}
+	}
+}
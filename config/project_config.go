@@ -11,10 +11,13 @@ package config
  */
 
 import (
+	"encoding/json"
 	"errors"
 	"fmt"
 	"github.com/bmatsuo/go-validate"
+	"strconv"
 	"strings"
+	"time"
 	"unicode"
 )
 
@@ -22,12 +25,9 @@ import (
 type Projects map[string]*Project
 
 func (config Projects) inheritanceGraph() configInheritanceGraph {
-	g := make(configInheritanceGraph, len(config))
+	g := makeConfigInheritanceGraph(len(config))
 	for v := range config {
-		g[v] = make(map[string]interface{}, len(config[v].Inherits))
-		for _, w := range config[v].Inherits {
-			g[v][w] = true
-		}
+		g.add(v, config[v].Inherits)
 	}
 	return g
 }
@@ -81,9 +81,10 @@ func (config Projects) Validate() (err error) {
 }
 
 type Project struct {
-	Inherits []string                      // Projects to inherit config from
-	Hooks    *ProjectHooksConfig           // Hooks that run at specific times
-	Files    map[string]*ProjectFileConfig // Project file specifications
+	Inherits   []string                      // Projects to inherit config from
+	Hooks      *ProjectHooksConfig           // Hooks that run at specific times
+	Files      map[string]*ProjectFileConfig // Project file specifications
+	Parameters map[string]*ParameterConfig   // Values for templates (.Vars)
 }
 
 func (config *Project) Validate() (err error) {
@@ -101,6 +102,24 @@ func (config *Project) Validate() (err error) {
 		}
 		return
 	})
+	if err != nil {
+		return
+	}
+	err = validate.Property("Hooks", config.Hooks)
+	if err != nil {
+		return
+	}
+	err = validate.PropertyFunc("Parameters", func() (err error) {
+		for k, param := range config.Parameters {
+			if strings.IndexFunc(k, unicode.IsSpace) > -1 {
+				return validate.Invalid("name", k)
+			}
+			if err = validate.Index(k, param); err != nil {
+				return
+			}
+		}
+		return
+	})
 	return
 }
 
@@ -124,6 +143,92 @@ func (config *Project) Merge(other *Project) {
 			file.Merge(otherFile)
 		}
 	}
+	if other.Parameters != nil {
+		if config.Parameters == nil {
+			config.Parameters = make(map[string]*ParameterConfig, len(other.Parameters))
+		}
+		for name, otherParam := range other.Parameters {
+			param, present := config.Parameters[name]
+			if !present {
+				param = new(ParameterConfig)
+				config.Parameters[name] = param
+			}
+			param.Merge(otherParam)
+		}
+	}
+}
+
+// Records which project contributed each value of a merged Project.
+type ProjectOrigins struct {
+	Files      map[string]*ProjectFileOrigins // Keyed like Project.Files
+	Parameters map[string]string              // The last project to set each parameter
+	Pre        []string                       // Origins of Hooks.Pre, by index
+	Post       []string                       // Origins of Hooks.Post, by index
+}
+
+// Records which project contributed each value of a merged ProjectFileConfig.
+type ProjectFileOrigins struct {
+	Path       string
+	Type       string
+	Templates  string
+	When       string
+	OnConflict string
+	Format     string
+	Hooks      string
+}
+
+// Record the values that Project.Merge takes from other, a config named name.
+// This must follow the rules of Project.Merge.
+func (origins *ProjectOrigins) merge(name string, other *Project) {
+	if other.Hooks != nil {
+		origins.Pre = prependOrigins(name, len(other.Hooks.Pre), origins.Pre)
+		origins.Post = prependOrigins(name, len(other.Hooks.Post), origins.Post)
+	}
+	if origins.Files == nil {
+		origins.Files = make(map[string]*ProjectFileOrigins, len(other.Files))
+	}
+	for key, otherFile := range other.Files {
+		file, present := origins.Files[key]
+		if !present {
+			file = new(ProjectFileOrigins)
+			origins.Files[key] = file
+		}
+		if otherFile.Path != "" {
+			file.Path = name
+		}
+		if otherFile.When != "" {
+			file.When = name
+		}
+		if otherFile.OnConflict != "" {
+			file.OnConflict = name
+		}
+		if otherFile.Format != "" {
+			file.Format = name
+		}
+		if otherFile.Hooks != nil {
+			file.Hooks = name
+		}
+		if otherFile.Type != "" {
+			file.Type = name
+			file.Templates = name
+		} else if otherFile.Templates != nil {
+			file.Templates = name
+		}
+	}
+	if origins.Parameters == nil {
+		origins.Parameters = make(map[string]string, len(other.Parameters))
+	}
+	for key := range other.Parameters {
+		origins.Parameters[key] = name
+	}
+}
+
+func prependOrigins(name string, n int, rest []string) []string {
+	merged := make([]string, 0, n+len(rest))
+	for i := 0; i < n; i++ {
+		merged = append(merged, name)
+	}
+	return append(merged, rest...)
 }
 
 type ProjectHooksConfig struct {
@@ -133,16 +238,131 @@ type ProjectHooksConfig struct {
 
 func (config *ProjectHooksConfig) Merge(other *ProjectHooksConfig) {
 	if other.Pre != nil {
-		config.Pre = append(other.Pre, config.Pre...)
+		config.Pre = prependHooks(other.Pre, config.Pre)
 	}
 	if other.Post != nil {
-		config.Post = append(other.Post, config.Post...)
+		config.Post = prependHooks(other.Post, config.Post)
 	}
 }
 
+func (config *ProjectHooksConfig) Validate() error {
+	err := validate.PropertyFunc("Pre", func() error { return validateHooks(config.Pre) })
+	if err != nil {
+		return err
+	}
+	return validate.PropertyFunc("Post", func() error { return validateHooks(config.Post) })
+}
+
+func validateHooks(hooks []*HookConfig) (err error) {
+	for i, hook := range hooks {
+		if err = validate.Index(i, hook); err != nil {
+			return
+		}
+	}
+	return
+}
+
+// A new slice containing hooks followed by rest. Unlike append, the backing
+// array of hooks (from the config being merged) is never modified.
+func prependHooks(hooks, rest []*HookConfig) []*HookConfig {
+	merged := make([]*HookConfig, 0, len(hooks)+len(rest))
+	merged = append(merged, hooks...)
+	return append(merged, rest...)
+}
+
 type HookConfig struct {
-	Cwd      string   // The working directory Cammonds should be executed from.
-	Commands []string // A list of commands executed in order.
+	Cwd             string            // The working directory Cammonds should be executed from.
+	Env             map[string]string // Variables added to the environment of Commands.
+	Commands        []HookCommand     // A list of commands executed in order.
+	Timeout         string            // The time each command may run (e.g. "30s"), or no limit.
+	ContinueOnError bool              // A failed command skips the rest of the hook instead of failing the generation.
+	Quiet           bool              // Output of Commands is only shown when one fails.
+}
+
+// The parsed Timeout, or 0 if it is empty.
+func (config *HookConfig) TimeoutDuration() (time.Duration, error) {
+	if config.Timeout == "" {
+		return 0, nil
+	}
+	d, err := time.ParseDuration(config.Timeout)
+	if err == nil && d <= 0 {
+		err = fmt.Errorf("not positive: %s", config.Timeout)
+	}
+	return d, err
+}
+
+// A hook command. In JSON a command is either a string, a command line run
+// with bash, or an array of strings, the arguments of a program that is run
+// directly. Arguments need no quoting and no shell is required.
+type HookCommand struct {
+	Shell string   // A bash command line
+	Args  []string // A program and its arguments, used when Shell is empty
+}
+
+// A shell command.
+func ShellCommand(cmd string) HookCommand { return HookCommand{Shell: cmd} }
+
+// A program with arguments.
+func ArgsCommand(args ...string) HookCommand { return HookCommand{Args: args} }
+
+// The command line; arguments are quoted when necessary.
+func (cmd HookCommand) String() string {
+	if cmd.Args == nil {
+		return cmd.Shell
+	}
+	args := make([]string, len(cmd.Args))
+	for i, arg := range cmd.Args {
+		if arg == "" || strings.IndexFunc(arg, needsQuote) >= 0 {
+			arg = strconv.Quote(arg)
+		}
+		args[i] = arg
+	}
+	return strings.Join(args, " ")
+}
+
+func needsQuote(r rune) bool {
+	return unicode.IsSpace(r) || strings.ContainsRune(`"'\$&|;<>()*?[]{}#~`+"`", r)
+}
+
+func (cmd HookCommand) MarshalJSON() ([]byte, error) {
+	if cmd.Args == nil {
+		return json.Marshal(cmd.Shell)
+	}
+	return json.Marshal(cmd.Args)
+}
+
+func (cmd *HookCommand) UnmarshalJSON(p []byte) error {
+	*cmd = HookCommand{}
+	if err := json.Unmarshal(p, &cmd.Shell); err == nil {
+		return nil
+	}
+	if err := json.Unmarshal(p, &cmd.Args); err != nil {
+		return fmt.Errorf("hook command must be a string or an array of strings: %s", p)
+	}
+	if len(cmd.Args) == 0 {
+		return errors.New("empty hook command")
+	}
+	return nil
+}
+
+// Requires the names in Env to be nonempty and free of '=' and Timeout to
+// be empty or a positive duration.
+func (config *HookConfig) Validate() error {
+	err := validate.PropertyFunc("Env", func() error {
+		for name := range config.Env {
+			if name == "" || strings.Contains(name, "=") {
+				return validate.Invalid("name", name)
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+	return validate.PropertyFunc("Timeout", func() error {
+		_, err := config.TimeoutDuration()
+		return err
+	})
 }
 
 func (config *HookConfig) Merge(other *ProjectHooksConfig) {
@@ -10,18 +10,104 @@ package config
  *  Description:
  */
 
-import ()
+import (
+	"fmt"
+
+	"github.com/bmatsuo/go-validate"
+)
+
+// The values of ProjectFileConfig.OnConflict. See the stage package for
+// their meaning. The "prompt" policy asks the user to choose one of the
+// others.
+var ConflictPolicies = []string{"fail", "skip", "overwrite", "backup", "prompt", "merge"}
+
+// The values of ProjectFileConfig.Format. Files with no Format are formatted
+// with "gofmt" when their Type is "go" and otherwise are not formatted.
+//
+//	none: write the file as rendered
+//	gofmt: format the file with gofmt
+//	imports: also merge, group and sort the file's imports
+var FormatModes = []string{"none", "gofmt", "imports"}
 
 type ProjectFileConfig struct {
-	Path      string   // a template
-	Type      string   // a 'filetype' that can be used in templates
-	Templates []string // template names
+	Path       string   // a template
+	Type       string   // a 'filetype' that can be used in templates
+	Templates  []string // template names
+	When       string   // a template; the file is skipped if it renders false (optional)
+	OnConflict string   // how to handle an existing file (optional, see ConflictPolicies)
+	Format     string   // how to format the rendered file (optional, see FormatModes)
+
+	// hooks run after the file is written (optional). They are rendered
+	// with the file's template context.
+	Hooks []*HookConfig
+}
+
+// Returns an error if policy is not one of ConflictPolicies.
+func CheckConflictPolicy(policy string) error {
+	for _, p := range ConflictPolicies {
+		if policy == p {
+			return nil
+		}
+	}
+	return fmt.Errorf("unknown policy: %q", policy)
+}
+
+// The format mode of the file (see FormatModes).
+func (config *ProjectFileConfig) FormatMode() string {
+	switch {
+	case config.Format != "":
+		return config.Format
+	case config.Type == "go":
+		return "gofmt"
+	}
+	return "none"
+}
+
+// Requires OnConflict and Format to be empty or known values and Hooks to be
+// valid.
+func (config *ProjectFileConfig) Validate() error {
+	err := validate.PropertyFunc("OnConflict", func() error {
+		if config.OnConflict == "" {
+			return nil
+		}
+		return CheckConflictPolicy(config.OnConflict)
+	})
+	if err != nil {
+		return err
+	}
+	err = validate.PropertyFunc("Format", func() error {
+		if config.Format == "" {
+			return nil
+		}
+		for _, mode := range FormatModes {
+			if config.Format == mode {
+				return nil
+			}
+		}
+		return fmt.Errorf("unknown format: %q", config.Format)
+	})
+	if err != nil {
+		return err
+	}
+	return validate.PropertyFunc("Hooks", func() error { return validateHooks(config.Hooks) })
 }
 
 func (config *ProjectFileConfig) Merge(other *ProjectFileConfig) {
 	if other.Path != "" {
 		config.Path = other.Path
 	}
+	if other.When != "" {
+		config.When = other.When
+	}
+	if other.OnConflict != "" {
+		config.OnConflict = other.OnConflict
+	}
+	if other.Format != "" {
+		config.Format = other.Format
+	}
+	if other.Hooks != nil {
+		config.Hooks = other.Hooks
+	}
 	if other.Type != "" {
 		config.Type = other.Type
 		config.Templates = other.Templates
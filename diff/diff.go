@@ -0,0 +1,126 @@
+/*
+Package diff compares and merges text files line by line.
+*/
+package diff
+
+import (
+	"strings"
+)
+
+// Split s into lines. Each line keeps its terminating newline; the last line
+// may have none.
+func Lines(s string) []string {
+	if s == "" {
+		return nil
+	}
+	lines := strings.SplitAfter(s, "\n")
+	if lines[len(lines)-1] == "" {
+		lines = lines[:len(lines)-1]
+	}
+	return lines
+}
+
+// A change replacing the lines a[A0:A1] with b[B0:B1].
+type Change struct{ A0, A1, B0, B1 int }
+
+// The changes transforming a into b, in order. The changes are minimal (Myers'
+// algorithm) and adjacent changes are combined.
+func Diff(a, b []string) []Change {
+	// trim the common prefix and suffix, which is most of a typical file.
+	pre := 0
+	for pre < len(a) && pre < len(b) && a[pre] == b[pre] {
+		pre++
+	}
+	suf := 0
+	for suf < len(a)-pre && suf < len(b)-pre && a[len(a)-1-suf] == b[len(b)-1-suf] {
+		suf++
+	}
+	changes := myers(a[pre:len(a)-suf], b[pre:len(b)-suf])
+	for i := range changes {
+		changes[i].A0 += pre
+		changes[i].A1 += pre
+		changes[i].B0 += pre
+		changes[i].B1 += pre
+	}
+	return changes
+}
+
+func myers(a, b []string) []Change {
+	n, m := len(a), len(b)
+	if n == 0 && m == 0 {
+		return nil
+	}
+	max := n + m
+	v := make([]int, 2*max+2)
+	var trace [][]int
+	var d int
+search:
+	for d = 0; d <= max; d++ {
+		trace = append(trace, append([]int(nil), v...))
+		for k := -d; k <= d; k += 2 {
+			var x int
+			if k == -d || (k != d && v[max+k-1] < v[max+k+1]) {
+				x = v[max+k+1]
+			} else {
+				x = v[max+k-1] + 1
+			}
+			y := x - k
+			for x < n && y < m && a[x] == b[y] {
+				x++
+				y++
+			}
+			v[max+k] = x
+			if x >= n && y >= m {
+				break search
+			}
+		}
+	}
+
+	// backtrack, marking the lines of a and b that are kept.
+	keptA := make([]bool, n)
+	keptB := make([]bool, m)
+	x, y := n, m
+	for ; d > 0; d-- {
+		v := trace[d]
+		k := x - y
+		var prevK int
+		if k == -d || (k != d && v[max+k-1] < v[max+k+1]) {
+			prevK = k + 1
+		} else {
+			prevK = k - 1
+		}
+		prevX := v[max+prevK]
+		prevY := prevX - prevK
+		for x > prevX && y > prevY {
+			x--
+			y--
+			keptA[x], keptB[y] = true, true
+		}
+		x, y = prevX, prevY
+	}
+	for x > 0 && y > 0 {
+		x--
+		y--
+		keptA[x], keptB[y] = true, true
+	}
+
+	var changes []Change
+	i, j := 0, 0
+	for i < n || j < m {
+		if i < n && j < m && keptA[i] && keptB[j] {
+			i++
+			j++
+			continue
+		}
+		c := Change{A0: i, B0: j}
+		for i < n && !keptA[i] {
+			i++
+		}
+		for j < m && !keptB[j] {
+			j++
+		}
+		c.A1, c.B1 = i, j
+		changes = append(changes, c)
+	}
+	return changes
+}
@@ -0,0 +1,60 @@
+package diff
+
+import (
+	"math/rand"
+	"strings"
+	"testing"
+)
+
+// Apply changes to a, which should produce b.
+func apply(a, b []string, changes []Change) []string {
+	var out []string
+	i := 0
+	for _, c := range changes {
+		out = append(out, a[i:c.A0]...)
+		out = append(out, b[c.B0:c.B1]...)
+		i = c.A1
+	}
+	return append(out, a[i:]...)
+}
+
+func TestDiff(t *testing.T) {
+	for i, test := range []struct {
+		a, b string
+		n    int
+	}{
+		{"", "", 0},
+		{"a\n", "a\n", 0},
+		{"", "a\nb\n", 1},
+		{"a\nb\n", "", 1},
+		{"a\nb\nc\n", "a\nx\nc\n", 1},
+		{"a\nb\nc\nd\n", "x\nb\nc\ny\n", 2},
+		{"a\nb\nc", "a\nb\nc\n", 1},
+	} {
+		a, b := Lines(test.a), Lines(test.b)
+		changes := Diff(a, b)
+		if len(changes) != test.n {
+			t.Errorf("test %d: %d changes (expected %d): %v", i, len(changes), test.n, changes)
+		}
+		if out := strings.Join(apply(a, b, changes), ""); out != test.b {
+			t.Errorf("test %d: changes produce %q", i, out)
+		}
+	}
+}
+
+func TestDiffRandom(t *testing.T) {
+	r := rand.New(rand.NewSource(1))
+	text := func() []string {
+		lines := make([]string, r.Intn(30))
+		for i := range lines {
+			lines[i] = string(rune('a'+r.Intn(4))) + "\n"
+		}
+		return lines
+	}
+	for i := 0; i < 500; i++ {
+		a, b := text(), text()
+		if out := strings.Join(apply(a, b, Diff(a, b)), ""); out != strings.Join(b, "") {
+			t.Fatalf("diff %q %q produces %q", a, b, out)
+		}
+	}
+}
@@ -0,0 +1,124 @@
+package diff
+
+import (
+	"strings"
+)
+
+// Labels for the sides of a conflict.
+type Labels struct{ Ours, Base, Theirs string }
+
+func writeConflict(out *strings.Builder, labels Labels, ours, base, theirs []string, withBase bool) {
+	out.WriteString("<<<<<<< " + labels.Ours + "\n")
+	writeLines(out, ours)
+	if withBase {
+		out.WriteString("||||||| " + labels.Base + "\n")
+		writeLines(out, base)
+	}
+	out.WriteString("=======\n")
+	writeLines(out, theirs)
+	out.WriteString(">>>>>>> " + labels.Theirs + "\n")
+}
+
+func writeRaw(out *strings.Builder, lines []string) {
+	for _, line := range lines {
+		out.WriteString(line)
+	}
+}
+
+// Write lines, terminating the last one if necessary so conflict markers
+// start on their own line.
+func writeLines(out *strings.Builder, lines []string) {
+	writeRaw(out, lines)
+	if n := len(lines); n > 0 && !strings.HasSuffix(lines[n-1], "\n") {
+		out.WriteString("\n")
+	}
+}
+
+// Combine two versions of a file with no common ancestor. Lines the versions
+// share are kept and each region where they differ becomes a conflict. The
+// number of conflicts is returned with the merged text.
+func Merge2(ours, theirs string, labels Labels) (string, int) {
+	a, b := Lines(ours), Lines(theirs)
+	out := new(strings.Builder)
+	conflicts := 0
+	i := 0
+	for _, c := range Diff(a, b) {
+		writeRaw(out, a[i:c.A0])
+		writeConflict(out, labels, a[c.A0:c.A1], nil, b[c.B0:c.B1], false)
+		conflicts++
+		i = c.A1
+	}
+	writeRaw(out, a[i:])
+	return out.String(), conflicts
+}
+
+// Merge the changes made to base in ours and in theirs (a three-way merge).
+// A region changed on only one side, or identically on both, takes that
+// change. A region changed differently on each side becomes a conflict showing
+// both versions and the original. The number of conflicts is returned with the
+// merged text.
+func Merge3(base, ours, theirs string, labels Labels) (string, int) {
+	o, a, b := Lines(base), Lines(ours), Lines(theirs)
+	ca, cb := Diff(o, a), Diff(o, b)
+	out := new(strings.Builder)
+	conflicts := 0
+	pos := 0 // next unwritten line of base
+	for len(ca) > 0 || len(cb) > 0 {
+		// gather the overlapping changes starting with the earliest.
+		var ga, gb []Change
+		lo, hi := -1, -1
+		take := func(cs *[]Change, g *[]Change) bool {
+			if len(*cs) == 0 {
+				return false
+			}
+			c := (*cs)[0]
+			if lo >= 0 && !(c.A0 < hi || (c.A0 == hi && c.A0 == c.A1) || (c.A0 == lo && lo == hi)) {
+				return false
+			}
+			if lo < 0 || c.A0 < lo {
+				lo = c.A0
+			}
+			if c.A1 > hi {
+				hi = c.A1
+			}
+			*g = append(*g, c)
+			*cs = (*cs)[1:]
+			return true
+		}
+		if len(cb) == 0 || (len(ca) > 0 && ca[0].A0 <= cb[0].A0) {
+			take(&ca, &ga)
+		} else {
+			take(&cb, &gb)
+		}
+		for take(&ca, &ga) || take(&cb, &gb) {
+		}
+
+		writeRaw(out, o[pos:lo])
+		pos = hi
+		switch {
+		case len(gb) == 0:
+			writeRaw(out, side(o, a, ga, lo, hi))
+		case len(ga) == 0:
+			writeRaw(out, side(o, b, gb, lo, hi))
+		default:
+			sa, sb := side(o, a, ga, lo, hi), side(o, b, gb, lo, hi)
+			if strings.Join(sa, "") == strings.Join(sb, "") {
+				writeRaw(out, sa)
+				break
+			}
+			writeConflict(out, labels, sa, o[lo:hi], sb, true)
+			conflicts++
+		}
+	}
+	writeRaw(out, o[pos:])
+	return out.String(), conflicts
+}
+
+// The lines of one side that replace base[lo:hi], given that side's changes
+// within that range.
+func side(base, lines []string, changes []Change, lo, hi int) []string {
+	first, last := changes[0], changes[len(changes)-1]
+	start := first.B0 - (first.A0 - lo)
+	end := last.B1 + (hi - last.A1)
+	return lines[start:end]
+}
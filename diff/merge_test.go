@@ -0,0 +1,48 @@
+package diff
+
+import (
+	"testing"
+)
+
+var labels = Labels{"ours", "base", "theirs"}
+
+func TestMerge3(t *testing.T) {
+	for i, test := range []struct {
+		base, ours, theirs string
+		merged             string
+		conflicts          int
+	}{
+		{"a\nb\nc\n", "a\nb\nc\n", "a\nb\nc\n", "a\nb\nc\n", 0},
+		{"a\nb\nc\n", "a\nB\nc\n", "a\nb\nc\n", "a\nB\nc\n", 0},
+		{"a\nb\nc\n", "a\nb\nc\n", "a\nb\nC\n", "a\nb\nC\n", 0},
+		{"a\nb\nc\nd\ne\n", "A\nb\nc\nd\ne\n", "a\nb\nc\nd\nE\n", "A\nb\nc\nd\nE\n", 0},
+		{"a\nb\nc\n", "a\nX\nc\n", "a\nX\nc\n", "a\nX\nc\n", 0},
+		{"a\nb\nc\n", "a\nb\nc\nours\n", "theirs\na\nb\nc\n", "theirs\na\nb\nc\nours\n", 0},
+		{"a\nb\nc", "a\nb\nc", "a\nb\nc\n", "a\nb\nc\n", 0},
+		{
+			"a\nb\nc\n", "a\nX\nc\n", "a\nY\nc\n",
+			"a\n<<<<<<< ours\nX\n||||||| base\nb\n=======\nY\n>>>>>>> theirs\nc\n", 1,
+		},
+		{
+			"a\n", "a\nx\n", "a\ny\n",
+			"a\n<<<<<<< ours\nx\n||||||| base\n=======\ny\n>>>>>>> theirs\n", 1,
+		},
+	} {
+		merged, n := Merge3(test.base, test.ours, test.theirs, labels)
+		if merged != test.merged || n != test.conflicts {
+			t.Errorf("test %d: merged (%d conflicts)\n%s", i, n, merged)
+		}
+	}
+}
+
+func TestMerge2(t *testing.T) {
+	merged, n := Merge2("a\nb\nc\n", "a\nx\nc\n", labels)
+	expect := "a\n<<<<<<< ours\nb\n=======\nx\n>>>>>>> theirs\nc\n"
+	if merged != expect || n != 1 {
+		t.Errorf("merged (%d conflicts)\n%s", n, merged)
+	}
+	merged, n = Merge2("a\n", "a\n", labels)
+	if merged != "a\n" || n != 0 {
+		t.Errorf("merged (%d conflicts)\n%s", n, merged)
+	}
+}
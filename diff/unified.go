@@ -0,0 +1,73 @@
+package diff
+
+import (
+	"fmt"
+	"strings"
+)
+
+// The number of unchanged lines shown around each change by Unified.
+const Context = 3
+
+// The changes from a (named from) to b (named to) in the unified format of
+// diff -u, with Context lines of context. Unified returns "" when a and b are
+// equal.
+func Unified(from, to, a, b string) string {
+	la, lb := Lines(a), Lines(b)
+	changes := Diff(la, lb)
+	if len(changes) == 0 {
+		return ""
+	}
+	out := new(strings.Builder)
+	fmt.Fprintf(out, "--- %s\n+++ %s\n", from, to)
+	for len(changes) > 0 {
+		// a hunk holds the changes separated by no more than twice the context.
+		n := 1
+		for n < len(changes) && changes[n].A0-changes[n-1].A1 <= 2*Context {
+			n++
+		}
+		hunk := changes[:n]
+		changes = changes[n:]
+
+		first, last := hunk[0], hunk[len(hunk)-1]
+		a0, a1 := first.A0-Context, last.A1+Context
+		if a0 < 0 {
+			a0 = 0
+		}
+		if a1 > len(la) {
+			a1 = len(la)
+		}
+		b0, b1 := first.B0-(first.A0-a0), last.B1+(a1-last.A1)
+		fmt.Fprintf(out, "@@ -%s +%s @@\n", hunkRange(a0, a1), hunkRange(b0, b1))
+		i := a0
+		for _, c := range hunk {
+			writePrefixed(out, " ", la[i:c.A0])
+			writePrefixed(out, "-", la[c.A0:c.A1])
+			writePrefixed(out, "+", lb[c.B0:c.B1])
+			i = c.A1
+		}
+		writePrefixed(out, " ", la[i:a1])
+	}
+	return out.String()
+}
+
+// The range of lines [lo, hi) in a hunk header. Lines are numbered from 1 and
+// an empty range is given by the line before it.
+func hunkRange(lo, hi int) string {
+	switch hi - lo {
+	case 0:
+		return fmt.Sprintf("%d,0", lo)
+	case 1:
+		return fmt.Sprint(lo + 1)
+	}
+	return fmt.Sprintf("%d,%d", lo+1, hi-lo)
+}
+
+func writePrefixed(out *strings.Builder, prefix string, lines []string) {
+	for _, line := range lines {
+		out.WriteString(prefix)
+		out.WriteString(line)
+		if !strings.HasSuffix(line, "\n") {
+			out.WriteString("\n\\ No newline at end of file\n")
+		}
+	}
+}
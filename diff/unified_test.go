@@ -0,0 +1,30 @@
+package diff
+
+import (
+	"testing"
+)
+
+func TestUnified(t *testing.T) {
+	for i, test := range []struct {
+		a, b, out string
+	}{
+		{"a\nb\n", "a\nb\n", ""},
+		{"", "a\n", "--- a\n+++ b\n@@ -0,0 +1 @@\n+a\n"},
+		{"a\nb\nc\n", "a\nx\nc\n", "--- a\n+++ b\n@@ -1,3 +1,3 @@\n a\n-b\n+x\n c\n"},
+		{"a\nb", "a\nb\n", "--- a\n+++ b\n@@ -1,2 +1,2 @@\n a\n-b\n\\ No newline at end of file\n+b\n"},
+		{
+			"1\n2\n3\n4\n5\n6\n7\n8\n9\n10\n11\n12\n",
+			"x\n2\n3\n4\n5\n6\n7\n8\n9\n10\n11\n",
+			"--- a\n+++ b\n@@ -1,4 +1,4 @@\n-1\n+x\n 2\n 3\n 4\n@@ -9,4 +9,3 @@\n 9\n 10\n 11\n-12\n",
+		},
+		{
+			"1\n2\n3\n4\n5\n6\n7\n",
+			"x\n2\n3\n4\n5\n6\ny\n",
+			"--- a\n+++ b\n@@ -1,7 +1,7 @@\n-1\n+x\n 2\n 3\n 4\n 5\n 6\n-7\n+y\n",
+		},
+	} {
+		if out := Unified("a", "b", test.a, test.b); out != test.out {
+			t.Errorf("test %d: unexpected diff\n%s", i, out)
+		}
+	}
+}
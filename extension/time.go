@@ -14,6 +14,8 @@ import (
 	"time"
 )
 
+// Template contexts replace the registered Time with the time of the project
+// (see project.Context).
 var timeFuncs = Register(Time(time.Now()))
 
 type Time time.Time
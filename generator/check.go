@@ -0,0 +1,202 @@
+package generator
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/bmatsuo/gonew/config"
+	"github.com/bmatsuo/gonew/templates"
+)
+
+// A problem found by Check.
+type Problem struct {
+	Pos     string // A template file and line ("path:line"), or a project
+	Msg     string
+	Warning bool // The problem does not keep projects from being generated
+}
+
+func (p *Problem) String() string {
+	if p.Warning {
+		return p.Pos + ": warning: " + p.Msg
+	}
+	return p.Pos + ": " + p.Msg
+}
+
+// A parsed template file of a namespace.
+type checkFile struct {
+	*templates.File
+	ns   string
+	defs []string // the templates of the file, which it may define
+	refs []*templates.Reference
+	used bool
+}
+
+// Check the templates and project types of the configuration. Every
+// template file is parsed on its own, and problems are found with template
+// references, the templates of project files, and templates of the external
+// sources that are never used. When every template parses, each project type
+// is rendered (without writing anything) for a target named "example", with
+// parameters lacking a default given sample values, and strictly if g.Strict.
+// Problems are sorted by position.
+func (g *Generator) Check(ctx context.Context) ([]*Problem, error) {
+	var problems []*Problem
+	report := func(pos, msg string, warning bool) {
+		problems = append(problems, &Problem{pos, msg, warning})
+	}
+
+	// parse every file. defs maps each namespace to its template names.
+	sources := g.sources()
+	defs := make(map[string]map[string]*checkFile, len(sources))
+	var files []*checkFile
+	var unparsed bool
+	for _, src := range sources {
+		srcFiles, err := templates.SourceFiles(src, ".t2")
+		if err != nil {
+			report(fmt.Sprint(src.Source), err.Error(), false)
+			unparsed = true
+			continue
+		}
+		defs[src.Name] = make(map[string]*checkFile, len(srcFiles))
+		for _, file := range srcFiles {
+			t, err := templates.Parse(file, funcs(new(config.Environment), time.Time{}))
+			if perr, ok := err.(*templates.ParseError); ok {
+				report(fmt.Sprintf("%s:%d", perr.Path, perr.Line), perr.Msg, false)
+				// the file's template exists, though it is broken.
+				defs[src.Name][file.Name] = &checkFile{File: file, ns: src.Name, used: true}
+				unparsed = true
+				continue
+			} else if err != nil {
+				return nil, err
+			}
+			cf := &checkFile{File: file, ns: src.Name, refs: templates.References(t)}
+			for _, tt := range t.Templates() {
+				if tt.Tree != nil && tt != t.Lookup("gonew") {
+					cf.defs = append(cf.defs, tt.Name())
+					defs[src.Name][tt.Name()] = cf
+				}
+			}
+			files = append(files, cf)
+		}
+	}
+
+	// resolve a name as the template set does, marking the file defining it
+	// as used. below resolves names in the namespaces before namespace i.
+	resolve := func(name string, below int) *checkFile {
+		if i := strings.Index(name, "/"); i >= 0 {
+			return defs[name[:i]][name[i+1:]]
+		}
+		if below < 0 {
+			below = len(sources)
+		}
+		for i := below - 1; i >= 0; i-- {
+			if cf := defs[sources[i].Name][name]; cf != nil {
+				return cf
+			}
+		}
+		return nil
+	}
+	nsIndex := make(map[string]int, len(sources))
+	for i, src := range sources {
+		nsIndex[src.Name] = i
+	}
+	for _, cf := range files {
+		for _, ref := range cf.refs {
+			pos := fmt.Sprintf("%s:%d", cf.Path, ref.Line)
+			if ref.Extend {
+				if base := resolve(ref.From, nsIndex[cf.ns]); base == nil {
+					report(pos, fmt.Sprintf("%s extends no template", ref.From), false)
+				} else {
+					base.used = true
+				}
+			} else if target := resolve(ref.Name, -1); target == nil {
+				report(pos, fmt.Sprintf("no template %q", ref.Name), false)
+			} else {
+				target.used = target.used || target != cf
+			}
+		}
+	}
+
+	names := make([]string, 0, len(g.Config.Projects))
+	for name := range g.Config.Projects {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		pos := "project " + name
+		proj, origins, err := g.Config.ProjectOrigins(name)
+		if err != nil {
+			report(pos, err.Error(), false)
+			continue
+		}
+		// missing templates are reported for the project naming them. They
+		// and templates failing to parse keep projects from being rendered.
+		var missing bool
+		for _, key := range sortedKeys(proj.Files) {
+			for _, t := range proj.Files[key].Templates {
+				if cf := resolve(t, -1); cf != nil {
+					cf.used = true
+					continue
+				}
+				missing = true
+				if origins.Files[key].Templates == name {
+					report(pos, fmt.Sprintf("file %s: no template %q", key, t), false)
+				}
+			}
+		}
+		if missing || unparsed {
+			continue
+		}
+		gen := *g
+		gen.Options = Options{Project: name, Name: "example", Vars: sampleVars(proj.Parameters), Strict: g.Strict, Time: g.Time}
+		r, err := gen.Render(ctx)
+		if err != nil {
+			report(pos, err.Error(), false)
+			continue
+		}
+		for _, warning := range r.Warnings {
+			report(pos, warning, true)
+		}
+	}
+
+	for _, cf := range files {
+		if !cf.used && cf.ns != templates.StandardNamespace {
+			report(cf.Path, "unused template "+cf.ns+"/"+cf.Name, true)
+		}
+	}
+	sort.SliceStable(problems, func(i, j int) bool { return problems[i].Pos < problems[j].Pos })
+	return problems, ctx.Err()
+}
+
+func sortedKeys(files map[string]*config.ProjectFileConfig) []string {
+	keys := make([]string, 0, len(files))
+	for key := range files {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// Values for the parameters without a default that would otherwise be
+// missing when rendering a sample project.
+func sampleVars(params map[string]*config.ParameterConfig) map[string]string {
+	vars := make(map[string]string)
+	for name, param := range params {
+		if param.Default != "" {
+			continue
+		}
+		candidates := []string{"", "example", "0", "false"}
+		if param.Type == config.ParameterChoice && len(param.Choices) > 0 {
+			candidates = param.Choices[:1]
+		}
+		for _, v := range candidates {
+			if _, err := param.Parse(v); err == nil {
+				vars[name] = v
+				break
+			}
+		}
+	}
+	return vars
+}
@@ -0,0 +1,93 @@
+package generator
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"testing/fstest"
+
+	"github.com/bmatsuo/gonew/config"
+	"github.com/bmatsuo/gonew/templates"
+)
+
+func checkProblems(t *testing.T, g *Generator) []string {
+	problems, err := g.Check(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	lines := make([]string, len(problems))
+	for i, p := range problems {
+		lines[i] = p.String()
+	}
+	return lines
+}
+
+func TestCheck(t *testing.T) {
+	g := testGenerator(t, Options{})
+	if problems := checkProblems(t, g); len(problems) != 0 {
+		t.Errorf("unexpected problems: %q", problems)
+	}
+
+	dir := t.TempDir()
+	for name, text := range map[string]string{
+		"doc.t2":    "{{extend .}} and {{template \"missing.t2\" .}}\n",
+		"unused.t2": "unused\n",
+	} {
+		if err := os.WriteFile(filepath.Join(dir, name), []byte(text), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	g.Config.ExternalTemplates = []config.ExternalTemplate{config.ExternalTemplate(dir)}
+	g.Config.Projects["pkg"].Files["Extra"] = &config.ProjectFileConfig{Path: "x", Type: "other", Templates: []string{"extra.t2"}}
+	expect := []string{
+		filepath.Join(dir, "doc.t2") + `:1: no template "missing.t2"`,
+		filepath.Join(dir, "unused.t2") + ": warning: unused template " + filepath.Base(dir) + "/unused.t2",
+		`project pkg: file Extra: no template "extra.t2"`,
+	}
+	if problems := checkProblems(t, g); strings.Join(problems, "\n") != strings.Join(expect, "\n") {
+		t.Errorf("unexpected problems: %q", problems)
+	}
+
+	g = testGenerator(t, Options{})
+	g.Standard = templates.SourceFS{FS: fstest.MapFS{
+		"pkg.go.t2": {Data: []byte("package {{.Project.Package}\n")},
+		"doc.t2":    {Data: []byte("{{.Project.Nope}}\n")},
+	}}
+	expect = []string{"pkg.go.t2:1: bad character U+007D '}'"}
+	if problems := checkProblems(t, g); strings.Join(problems, "\n") != strings.Join(expect, "\n") {
+		t.Errorf("unexpected problems: %q", problems)
+	}
+	g.Standard = templates.SourceFS{FS: fstest.MapFS{
+		"pkg.go.t2": {Data: []byte("package {{.Project.Nope}}\n")},
+		"doc.t2":    {Data: []byte("doc\n")},
+	}}
+	problems := checkProblems(t, g)
+	if len(problems) != len(g.Config.Projects) {
+		t.Errorf("unexpected problems: %q", problems)
+	}
+	for _, p := range problems {
+		if !strings.Contains(p, "can't evaluate field Nope") {
+			t.Errorf("unexpected problem: %s", p)
+		}
+	}
+
+	g.Standard = templates.SourceFS{FS: fstest.MapFS{
+		"pkg.go.t2": {Data: []byte("package {{.Package}}{{if false}}{{.Env.User.Nmae}}{{end}}\n")},
+		"doc.t2":    {Data: []byte("doc\n")},
+	}}
+	if problems := checkProblems(t, g); len(problems) != 0 {
+		t.Errorf("unexpected problems: %q", problems)
+	}
+	g.Strict = true
+	problems = checkProblems(t, g)
+	if len(problems) != len(g.Config.Projects) {
+		t.Errorf("unexpected problems: %q", problems)
+	}
+	for _, p := range problems {
+		if !strings.Contains(p, "can't evaluate field Nmae") {
+			t.Errorf("unexpected problem: %s", p)
+		}
+	}
+}
@@ -0,0 +1,200 @@
+/*
+Package generator generates projects from a gonew configuration.
+
+A Generator renders a project type for a target in memory, then writes the
+files and runs the project's hooks. Everything is staged (see the stage
+package) so a failed generation leaves nothing behind. Errors are returned,
+never fatal, so the package can be used by other programs and tests.
+
+	gen := generator.New(conf, generator.Options{Project: "pkg", Name: "mp3lib"})
+	result, err := gen.Generate(ctx)
+*/
+package generator
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"path/filepath"
+	"time"
+
+	"github.com/bmatsuo/gonew/config"
+	"github.com/bmatsuo/gonew/manifest"
+	"github.com/bmatsuo/gonew/output"
+	"github.com/bmatsuo/gonew/project"
+	"github.com/bmatsuo/gonew/stage"
+)
+
+// What to generate and how.
+type Options struct {
+	Project     string            // The project type (default: the config's default)
+	Environment string            // The environment (default: the config's default)
+	Name        string            // The target name, on which file names are based
+	Package     string            // The package name (default: Name)
+	Module      string            // The module path (see project.WithModule)
+	Root        string            // The output directory (see project.WithRoot)
+	Vars        map[string]string // Parameter values, parsed by their type
+	OnConflict  string            // The policy for existing files (default "fail")
+	Manifest    bool              // Record the generation in the project's manifest
+	DryRun      bool              // Render the project but write nothing and run no hooks
+	Strict      bool              // Fail templates using missing map keys or fields (see templates.Interface)
+	Time        time.Time         // When the project is generated, for templates and the manifest (default: now)
+
+	// Receives the files instead of the file system. Hooks are not run and
+	// the manifest is recorded as if the project were new.
+	Output output.Backend
+
+	// Asks for the value of a parameter not in Vars. Without it parameters
+	// take their defaults.
+	PromptVar func(name string, param *config.ParameterConfig) (interface{}, error)
+
+	// Chooses how to handle an existing file whose policy is "prompt".
+	// Without it such files are an error.
+	PromptConflict func(rel string) (stage.Policy, error)
+
+	// The standard streams of hooks (see exec.Cmd).
+	Stdin          io.Reader
+	Stdout, Stderr io.Writer
+}
+
+// Generates projects configured by Config.
+type Generator struct {
+	Config *config.Gonew
+	Options
+
+	Version      string      // The version of gonew recorded in manifests
+	Standard     interface{} // The standard templates (default: templates.Standard())
+	StandardName string      // How manifests record Standard (default "builtin")
+}
+
+// Create a Generator for the configuration conf.
+func New(conf *config.Gonew, opts Options) *Generator {
+	return &Generator{Config: conf, Options: opts}
+}
+
+// A rendered file.
+type File struct {
+	Path       string   // Under the output directory
+	Content    []byte   // The rendered and formatted content
+	Templates  []string // The templates rendered (in order) to produce Content
+	OnConflict string   // The policy for an existing file (see config.ConflictPolicies)
+	Hooks      []*Hook  // Run after the file is written
+}
+
+// A hook with its working directory, environment and commands rendered.
+type Hook struct {
+	Cwd      string            // Under the output directory, unless absolute
+	Env      map[string]string // Added to the environment of Commands
+	Commands []config.HookCommand
+
+	Timeout         time.Duration // The time each command may run, or 0 for no limit
+	ContinueOnError bool          // A failed command skips the rest of the hook
+	Quiet           bool          // Output is only shown when a command fails
+}
+
+// A hook command that was run.
+type HookRun struct {
+	Stage    string // "pre", "post" or "file"
+	Cwd      string
+	Command  config.HookCommand
+	Exit     int // The exit status, or -1 if the command did not exit
+	Duration time.Duration
+	Err      error  // Why the command failed, or nil
+	Ignored  bool   // Err did not fail the generation (see Hook.ContinueOnError)
+	Output   []byte // The combined output of a Quiet hook
+}
+
+// A generated project.
+type Result struct {
+	Project project.Interface
+
+	// Describes the generation. Its Files are set when the generation is
+	// recorded in the project's manifest.
+	Generation *manifest.Generation
+
+	Files     []*File  // Sorted by path, ending with the manifest if recorded
+	Skipped   []string // Files omitted by their When expression, sorted
+	Pre, Post []*Hook
+	Hooks     []*HookRun // The hook commands run, in order
+	Warnings  []string   // Problems that did not stop generation, like ambiguous template names
+	Conflicts []string   // Files written with conflict markers by the merge policy, with paths like Files
+}
+
+// The files in the project directory with their paths relative to it and
+// slash-separated, as in a manifest. It is an error for a file to lie
+// outside of the project directory.
+func (r *Result) ProjectFiles() ([]*File, error) {
+	files := make([]*File, len(r.Files))
+	for i, file := range r.Files {
+		rel, ok := relativePath(r.Project.Prefix(), file.Path)
+		if !ok {
+			return nil, fmt.Errorf("file outside of the project: %s", file.Path)
+		}
+		pfile := *file
+		pfile.Path = filepath.ToSlash(rel)
+		files[i] = &pfile
+	}
+	return files, nil
+}
+
+// Whether the project has hooks, for files or the project.
+func (r *Result) HasHooks() bool {
+	for _, file := range r.Files {
+		if len(file.Hooks) > 0 {
+			return true
+		}
+	}
+	return len(r.Pre)+len(r.Post) > 0
+}
+
+// Render the project in memory. Nothing is written and no hooks are run.
+func (g *Generator) Render(ctx context.Context) (*Result, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	envName, projType := g.Environment, g.Project
+	if envName == "" {
+		envName = g.Config.Default.Environment
+	}
+	if projType == "" {
+		projType = g.Config.Default.Project
+	}
+	projConfig, proj, err := g.newProject(envName, projType)
+	if err != nil {
+		return nil, err
+	}
+	ts, err := g.loadTemplates(proj)
+	if err != nil {
+		return nil, err
+	}
+	r, err := g.render(ts, projConfig, proj)
+	if err != nil {
+		return nil, err
+	}
+	r.Generation = g.newGeneration(projType, envName, proj)
+	if g.Manifest {
+		// other output holds a new project, whatever is on disk.
+		mfile, err := manifestFile(proj.Prefix(), g.Output == nil, r.Generation, r.Files)
+		if err != nil {
+			return nil, fmt.Errorf("manifest: %v", err)
+		}
+		if mfile != nil {
+			r.Files = append(r.Files, mfile)
+		}
+	}
+	return r, nil
+}
+
+// Render the project, then write its files and run its hooks unless DryRun
+// is set. Files are written to Output, or else staged in the output
+// directory with the hooks and moved into place once all have succeeded.
+// If anything fails, or ctx is done, nothing is written. Hook commands are
+// killed when ctx is done. A failure after rendering returns the Result
+// along with the error, so the hooks that ran can be reported.
+func (g *Generator) Generate(ctx context.Context) (*Result, error) {
+	r, err := g.Render(ctx)
+	if err != nil || g.DryRun {
+		return r, err
+	}
+	return r, g.write(ctx, r)
+}
@@ -0,0 +1,317 @@
+package generator
+
+import (
+	"bytes"
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"testing/fstest"
+	"time"
+
+	"github.com/bmatsuo/gonew/config"
+	"github.com/bmatsuo/gonew/manifest"
+	"github.com/bmatsuo/gonew/output"
+	"github.com/bmatsuo/gonew/templates"
+)
+
+const testConfig = `{
+	"Default": {"Environment": "default", "Project": "pkg"},
+	"Environments": {
+		"default": {
+			"BaseImportPath": "example.com",
+			"User": {"Name": "Gopher", "Email": "gopher@example.com"}
+		}
+	},
+	"Projects": {
+		"pkg": {
+			"Parameters": {"Doc": {"Type": "bool", "Default": "false"}},
+			"Files": {
+				"Pkg": {"Path": "{{.Project.Name}}/{{.Project.Name}}.go", "Type": "go", "Templates": ["pkg.go.t2"]},
+				"Doc": {"Path": "{{.Project.Name}}/doc.txt", "Type": "other", "When": "{{.Project.Vars.Doc}}", "Templates": ["doc.t2"]}
+			}
+		},
+		"hooked": {
+			"Inherits": ["pkg"],
+			"Hooks": {"Post": [{"Cwd": "{{.Project.Name}}", "Commands": ["exit 3"]}]}
+		},
+		"env": {
+			"Inherits": ["pkg"],
+			"Hooks": {"Post": [{
+				"Cwd": "{{.Project.Name}}",
+				"Env": {"GREETING": "hello {{.Project.Name}}"},
+				"Commands": [
+					["mkdir", "a dir"],
+					"echo \"$GREETING $GONEW_PACKAGE $GONEW_IMPORT\" > \"a dir/env\"",
+					"test -f \"$GONEW_ROOT/mp3/mp3.go\""
+				]
+			}]}
+		},
+		"script": {
+			"Inherits": ["pkg"],
+			"Files": {
+				"Script": {
+					"Path": "{{.Project.Name}}/run.sh",
+					"Type": "other",
+					"Templates": ["doc.t2"],
+					"Hooks": [{"Commands": [["chmod", "+x", "{{.File.Path}}"], "test -x {{.File.Name}} || test -x {{.File.Path}}"]}]
+				}
+			}
+		},
+		"stateful": {
+			"Inherits": ["pkg"],
+			"Hooks": {"Post": [{"Cwd": "{{.Project.Name}}", "Commands": ["mkdir -p .state && echo new > .state/HEAD"]}]}
+		},
+		"policies": {
+			"Inherits": ["pkg"],
+			"Hooks": {"Post": [
+				{"Timeout": "100ms", "ContinueOnError": true, "Commands": [["sleep", "5"], ["touch", "skipped"]]},
+				{"Quiet": true, "ContinueOnError": true, "Commands": ["echo quiet; exit 2"]},
+				{"Quiet": true, "Commands": [["touch", "ran"]]}
+			]}
+		}
+	}
+}`
+
+var testTemplates = fstest.MapFS{
+	"pkg.go.t2": {Data: []byte("package   {{.Project.Package}}\n")},
+	"doc.t2":    {Data: []byte("{{.Project.Name}} by {{name}}\n")},
+}
+
+func testGenerator(t *testing.T, opts Options) *Generator {
+	conf := new(config.Gonew)
+	if err := conf.UnmarshalReaderJSON(strings.NewReader(testConfig)); err != nil {
+		t.Fatal(err)
+	}
+	g := New(conf, opts)
+	g.Version = "test"
+	g.Standard = templates.SourceFS{FS: testTemplates}
+	return g
+}
+
+func TestGenerate(t *testing.T) {
+	root := filepath.Join(t.TempDir(), "out")
+	g := testGenerator(t, Options{Name: "mp3", Root: root, Vars: map[string]string{"Doc": "true"}, Manifest: true})
+	r, err := g.Generate(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(r.Files) != 3 || len(r.Skipped) != 0 {
+		t.Errorf("unexpected files %d and skipped %v", len(r.Files), r.Skipped)
+	}
+	p, err := os.ReadFile(filepath.Join(root, "mp3", "mp3.go"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(p) != "package mp3\n" {
+		t.Errorf("unexpected content: %q", p)
+	}
+	m, err := manifest.Read(filepath.Join(root, "mp3"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(m.Generations) != 1 || m.Generations[0].Project != "pkg" || len(m.Generations[0].Files) != 2 {
+		t.Errorf("unexpected manifest: %+v", m.Generations)
+	}
+}
+
+func TestRender(t *testing.T) {
+	g := testGenerator(t, Options{Name: "mp3"})
+	r, err := g.Render(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(r.Files) != 1 || len(r.Skipped) != 1 || r.Skipped[0] != "Doc" {
+		t.Fatalf("unexpected files %d and skipped %v", len(r.Files), r.Skipped)
+	}
+	files, err := r.ProjectFiles()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if files[0].Path != "mp3.go" || files[0].OnConflict != "fail" {
+		t.Errorf("unexpected file: %+v", files[0])
+	}
+	if _, err := os.Lstat("mp3"); !os.IsNotExist(err) {
+		t.Errorf("render wrote files")
+	}
+
+	when := time.Date(2006, time.January, 2, 15, 4, 5, 0, time.UTC)
+	g = testGenerator(t, Options{Name: "mp3", Time: when})
+	if r, err = g.Render(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	if !r.Generation.Time.Equal(when) || !r.Project.Time().Equal(when) {
+		t.Errorf("unexpected time: %v", r.Generation.Time)
+	}
+
+	g = testGenerator(t, Options{Name: "mp3", Vars: map[string]string{"Doc": "maybe"}})
+	if _, err := g.Render(context.Background()); err == nil {
+		t.Errorf("invalid parameter accepted")
+	}
+}
+
+func TestRenderContext(t *testing.T) {
+	fsys := fstest.MapFS{
+		"pkg.go.t2": {Data: []byte("package {{.Project.Package}} // {{.Nope}} {{(index . \"Env\").User.Name}}\n")},
+		"doc.t2":    testTemplates["doc.t2"],
+	}
+	g := testGenerator(t, Options{Name: "mp3"})
+	g.Standard = templates.SourceFS{FS: fsys}
+	r, err := g.Render(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if content := string(r.Files[0].Content); content != "package mp3 // <no value> Gopher\n" {
+		t.Errorf("unexpected content: %q", content)
+	}
+
+	g = testGenerator(t, Options{Name: "mp3", Strict: true})
+	g.Standard = templates.SourceFS{FS: fsys}
+	if _, err := g.Render(context.Background()); err == nil {
+		t.Errorf("strict render succeeded")
+	}
+}
+
+func TestGenerateOutput(t *testing.T) {
+	mem := new(output.Memory)
+	g := testGenerator(t, Options{Project: "hooked", Name: "mp3", Root: "nonexistent", Output: mem})
+	if _, err := g.Generate(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	if f := mem.File("mp3/mp3.go"); f == nil || string(f.Content) != "package mp3\n" {
+		t.Errorf("unexpected file: %v", f)
+	}
+	if _, err := os.Lstat("nonexistent"); !os.IsNotExist(err) {
+		t.Errorf("output directory created")
+	}
+}
+
+func TestGenerateHookFailure(t *testing.T) {
+	root := filepath.Join(t.TempDir(), "out")
+	g := testGenerator(t, Options{Project: "hooked", Name: "mp3", Root: root})
+	if _, err := g.Generate(context.Background()); err == nil || !strings.Contains(err.Error(), "exit 3") {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if _, err := os.Lstat(root); !os.IsNotExist(err) {
+		t.Errorf("output directory left behind")
+	}
+}
+
+func TestGenerateHookEnv(t *testing.T) {
+	root := filepath.Join(t.TempDir(), "out")
+	g := testGenerator(t, Options{Project: "env", Name: "mp3", Root: root})
+	if _, err := g.Generate(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	p, err := os.ReadFile(filepath.Join(root, "mp3", "a dir", "env"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(p) != "hello mp3 mp3 example.com/mp3\n" {
+		t.Errorf("unexpected env: %q", p)
+	}
+}
+
+func TestGenerateFileHooks(t *testing.T) {
+	root := filepath.Join(t.TempDir(), "out")
+	g := testGenerator(t, Options{Project: "script", Name: "mp3", Root: root})
+	r, err := g.Generate(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(r.Hooks) != 2 || r.Hooks[0].Stage != "file" || r.Hooks[0].Command.String() != "chmod +x mp3/run.sh" {
+		t.Fatalf("unexpected hooks run: %+v", r.Hooks)
+	}
+	info, err := os.Stat(filepath.Join(root, "mp3", "run.sh"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if info.Mode()&0100 == 0 {
+		t.Errorf("script not executable: %v", info.Mode())
+	}
+}
+
+func TestGenerateExistingHookOutput(t *testing.T) {
+	root := filepath.Join(t.TempDir(), "out")
+	state := filepath.Join(root, "mp3", ".state")
+	if err := os.MkdirAll(state, 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(state, "HEAD"), []byte("old\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(root, "mp3", "mp3.go"), []byte("package old\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	g := testGenerator(t, Options{Project: "stateful", Name: "mp3", Root: root, OnConflict: "overwrite"})
+	if _, err := g.Generate(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	for path, expect := range map[string]string{"mp3/.state/HEAD": "old\n", "mp3/mp3.go": "package mp3\n"} {
+		p, err := os.ReadFile(filepath.Join(root, filepath.FromSlash(path)))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if string(p) != expect {
+			t.Errorf("unexpected content of %s: %q", path, p)
+		}
+	}
+}
+
+func TestGenerateHookPolicies(t *testing.T) {
+	root := filepath.Join(t.TempDir(), "out")
+	stderr := new(bytes.Buffer)
+	g := testGenerator(t, Options{Project: "policies", Name: "mp3", Root: root, Stderr: stderr})
+	r, err := g.Generate(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(r.Hooks) != 3 {
+		t.Fatalf("unexpected hooks run: %d", len(r.Hooks))
+	}
+	if run := r.Hooks[0]; run.Exit != -1 || !run.Ignored || run.Err == nil || !strings.Contains(run.Err.Error(), "timed out") {
+		t.Errorf("unexpected timeout: %+v", run)
+	}
+	if run := r.Hooks[1]; run.Exit != 2 || !run.Ignored || string(run.Output) != "quiet\n" {
+		t.Errorf("unexpected failure: %+v", run)
+	}
+	if run := r.Hooks[2]; run.Exit != 0 || run.Err != nil || run.Stage != "post" {
+		t.Errorf("unexpected success: %+v", run)
+	}
+	if stderr.String() != "quiet\n" {
+		t.Errorf("unexpected stderr: %q", stderr)
+	}
+	if _, err := os.Lstat(filepath.Join(root, "skipped")); !os.IsNotExist(err) {
+		t.Errorf("command after a failure was run")
+	}
+	if _, err := os.Lstat(filepath.Join(root, "ran")); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestRunHookTimeout(t *testing.T) {
+	g := testGenerator(t, Options{})
+	hook := &Hook{Timeout: 300 * time.Millisecond, Quiet: true}
+	start := time.Now()
+	run := g.runHook(context.Background(), hook, config.ShellCommand("sleep 3; true"), t.TempDir(), nil)
+	if d := time.Since(start); d > 2*time.Second {
+		t.Errorf("hook ran for %v", d)
+	}
+	if run.Err == nil || !strings.Contains(run.Err.Error(), "timed out") {
+		t.Errorf("unexpected error: %v", run.Err)
+	}
+}
+
+func TestGenerateCanceled(t *testing.T) {
+	root := filepath.Join(t.TempDir(), "out")
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	g := testGenerator(t, Options{Name: "mp3", Root: root})
+	if _, err := g.Generate(ctx); err != context.Canceled {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if _, err := os.Lstat(root); !os.IsNotExist(err) {
+		t.Errorf("output directory created")
+	}
+}
@@ -0,0 +1,8 @@
+//go:build !unix
+
+package generator
+
+import "os/exec"
+
+// Killing proc only kills its process on this system.
+func processGroup(proc *exec.Cmd) {}
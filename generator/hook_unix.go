@@ -0,0 +1,15 @@
+//go:build unix
+
+package generator
+
+import (
+	"os/exec"
+	"syscall"
+)
+
+// Run proc in its own process group, so killing it also kills the commands it
+// started, which could otherwise outlive it and keep its output open.
+func processGroup(proc *exec.Cmd) {
+	proc.SysProcAttr = &syscall.SysProcAttr{Setpgid: true}
+	proc.Cancel = func() error { return syscall.Kill(-proc.Process.Pid, syscall.SIGKILL) }
+}
@@ -0,0 +1,360 @@
+package generator
+
+import (
+	"bytes"
+	"fmt"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"strings"
+	"text/template"
+	"time"
+
+	"github.com/bmatsuo/gonew/config"
+	"github.com/bmatsuo/gonew/gosrc"
+	"github.com/bmatsuo/gonew/manifest"
+	"github.com/bmatsuo/gonew/project"
+	"github.com/bmatsuo/gonew/templates"
+)
+
+// Resolve the environment, project type and parameters of the project.
+func (g *Generator) newProject(envName, projType string) (*config.Project, project.Interface, error) {
+	env, err := g.Config.Environment(envName)
+	if err != nil {
+		return nil, nil, err
+	}
+	projConfig, err := g.Config.Project(projType)
+	if err != nil {
+		return nil, nil, err
+	}
+	values, err := resolveVars(projConfig.Parameters, g.Vars, g.PromptVar)
+	if err != nil {
+		return nil, nil, err
+	}
+	pkg := g.Package
+	if pkg == "" {
+		pkg = g.Name
+	}
+	projOpts := []project.Option{project.WithVars(values)}
+	if !g.Time.IsZero() {
+		projOpts = append(projOpts, project.WithTime(g.Time))
+	}
+	if g.Module != "" {
+		projOpts = append(projOpts, project.WithModule(g.Module))
+	}
+	if g.Root != "" {
+		projOpts = append(projOpts, project.WithRoot(g.Root))
+	}
+	return projConfig, project.New(g.Name, pkg, env, projOpts...), nil
+}
+
+// Load the standard and external templates for proj.
+func (g *Generator) loadTemplates(proj project.Interface) (templates.Interface, error) {
+	ts := templates.New(".t2")
+	if err := ts.Funcs(funcs(proj.Env(), proj.Time())); err != nil {
+		return nil, err
+	}
+	if g.Strict {
+		ts.Strict()
+	}
+	for i, src := range g.sources() {
+		if err := ts.Source(src); err != nil && i == 0 {
+			return nil, fmt.Errorf("templates: %v", err)
+		} else if err != nil {
+			return nil, fmt.Errorf("external templates %s: %v", src.Source, err)
+		}
+	}
+	return ts, nil
+}
+
+// The template sources, lowest precedence first: the standard templates and
+// then the external templates, the first of which take precedence.
+func (g *Generator) sources() []templates.Namespace {
+	standard := g.Standard
+	if standard == nil {
+		standard = templates.Standard()
+	}
+	sources := []templates.Namespace{{Name: templates.StandardNamespace, Source: standard}}
+	for i := len(g.Config.ExternalTemplates) - 1; i >= 0; i-- {
+		ext := g.Config.ExternalTemplates[i]
+		sources = append(sources, templates.Namespace{Name: ext.Namespace(), Source: templates.SourceDirectory(ext.Dir())})
+	}
+	return sources
+}
+
+// Describe the template sources, highest precedence first.
+func (g *Generator) templateSources() []string {
+	sources := make([]string, 0, len(g.Config.ExternalTemplates)+1)
+	for _, ext := range g.Config.ExternalTemplates {
+		sources = append(sources, string(ext))
+	}
+	if g.StandardName == "" {
+		return append(sources, "builtin")
+	}
+	return append(sources, g.StandardName)
+}
+
+// A manifest generation of proj, without files.
+func (g *Generator) newGeneration(projType, envName string, proj project.Interface) *manifest.Generation {
+	gen := &manifest.Generation{
+		Version:     g.Version,
+		Project:     projType,
+		Environment: envName,
+		Name:        proj.Name(),
+		Package:     proj.Package(),
+		Module:      proj.Module(),
+		Vars:        make(map[string]string, len(proj.Vars())),
+		Templates:   g.templateSources(),
+		Time:        proj.Time(),
+	}
+	for k, v := range proj.Vars() {
+		gen.Vars[k] = fmt.Sprint(v)
+	}
+	return gen
+}
+
+func renderHooks(ts templates.Interface, tenv templates.Environment, root string, hooks ...*config.HookConfig) ([]*Hook, error) {
+	rendered := make([]*Hook, 0, len(hooks))
+	for _, hook := range hooks {
+		cwd, err := tenv.RenderTextAsString(ts, "cwd_", hook.Cwd)
+		if err != nil {
+			return nil, fmt.Errorf("hook cwd template: %v", err)
+		}
+		if !filepath.IsAbs(cwd) {
+			cwd = filepath.Join(root, cwd)
+		}
+		timeout, err := hook.TimeoutDuration()
+		if err != nil {
+			return nil, fmt.Errorf("hook timeout: %v", err)
+		}
+		h := &Hook{
+			Cwd:             cwd,
+			Env:             make(map[string]string, len(hook.Env)),
+			Timeout:         timeout,
+			ContinueOnError: hook.ContinueOnError,
+			Quiet:           hook.Quiet,
+		}
+		for name, _val := range hook.Env {
+			val, err := tenv.RenderTextAsString(ts, "env_", _val)
+			if err != nil {
+				return nil, fmt.Errorf("hook env %s template: %v", name, err)
+			}
+			h.Env[name] = val
+		}
+		for _, _cmd := range hook.Commands {
+			var cmd config.HookCommand
+			if _cmd.Args == nil {
+				cmd.Shell, err = tenv.RenderTextAsString(ts, "cmd_", _cmd.Shell)
+			}
+			for _, _arg := range _cmd.Args {
+				var arg string
+				if arg, err = tenv.RenderTextAsString(ts, "arg_", _arg); err != nil {
+					break
+				}
+				cmd.Args = append(cmd.Args, arg)
+			}
+			if err != nil {
+				return nil, fmt.Errorf("hook template: %v", err)
+			}
+			h.Commands = append(h.Commands, cmd)
+		}
+		rendered = append(rendered, h)
+	}
+	return rendered, nil
+}
+
+// Whether the rendered text of a file's When expression selects the file.
+// Blank output, "false", "0" and "<no value>" (a missing key) are false.
+func truthy(when string) bool {
+	switch strings.TrimSpace(when) {
+	case "", "false", "0", "<no value>":
+		return false
+	}
+	return true
+}
+
+// The data templates of proj are rendered with, the typed context when
+// rendering strictly (see project.TemplateContext).
+func (g *Generator) context(file, filetype string, proj project.Interface) interface{} {
+	ctx := project.Context(file, filetype, proj)
+	if g.Strict {
+		return ctx
+	}
+	return ctx.Map()
+}
+
+// Render the hooks and files of a project.
+func (g *Generator) render(ts templates.Interface, projConfig *config.Project, proj project.Interface) (*Result, error) {
+	projTemplEnv := templates.Env(g.context("", "", proj))
+	r := &Result{Project: proj}
+	var err error
+	if projConfig.Hooks != nil {
+		if r.Pre, err = renderHooks(ts, projTemplEnv, proj.Root(), projConfig.Hooks.Pre...); err != nil {
+			return nil, err
+		}
+		if r.Post, err = renderHooks(ts, projTemplEnv, proj.Root(), projConfig.Hooks.Post...); err != nil {
+			return nil, err
+		}
+	}
+
+	r.Files = make([]*File, 0, len(projConfig.Files))
+	warnings := newWarnings(ts.Warnings())
+	for name, file := range projConfig.Files {
+		if file.When != "" {
+			when, err := projTemplEnv.RenderTextAsString(ts, "when_", file.When)
+			if err != nil {
+				return nil, fmt.Errorf("%s: %v", name, err)
+			}
+			if !truthy(when) {
+				r.Skipped = append(r.Skipped, name)
+				continue
+			}
+		}
+		path, err := projTemplEnv.RenderTextAsString(ts, "pre_", file.Path)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %v", name, err)
+		}
+		relpath := path
+		if !filepath.IsAbs(relpath) {
+			relpath = filepath.Join(proj.Root(), relpath)
+		}
+		filetype := file.Type
+
+		fileTemplEnv := templates.Env(g.context(path, filetype, proj))
+		hooks, err := renderHooks(ts, fileTemplEnv, proj.Root(), file.Hooks...)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %v", name, err)
+		}
+		// the file's templates collect imports with their own set of templates.
+		fileTemplates, err := ts.Clone()
+		if err != nil {
+			return nil, fmt.Errorf("%s: %v", name, err)
+		}
+		imports := &gosrc.ImportSet{Local: proj.Module()}
+		fileTemplates.Funcs(template.FuncMap{"import": imports.Func})
+		fileBuf := new(bytes.Buffer)
+		starts := make([]int, len(file.Templates))
+		for i, t := range file.Templates {
+			starts[i] = fileBuf.Len()
+			if err := fileTemplEnv.Render(fileBuf, fileTemplates, t); err != nil {
+				return nil, fmt.Errorf("%s: %v", name, err)
+			}
+		}
+		warnings.add(fileTemplates.Warnings())
+		// the import declaration shifts the output of later templates.
+		rendered := imports.Expand(fileBuf.Bytes())
+		if i := bytes.Index(fileBuf.Bytes(), []byte(gosrc.Placeholder)); i >= 0 {
+			for j := range starts {
+				if starts[j] > i {
+					starts[j] += len(rendered) - fileBuf.Len()
+				}
+			}
+		}
+		content, err := formatFile(file.FormatMode(), rendered, proj.Module())
+		if err, ok := err.(*gosrc.SyntaxError); ok {
+			return nil, fmt.Errorf("%s:%v (%s)", relpath, err, templateLine(rendered, file.Templates, starts, err.Line))
+		} else if err != nil {
+			return nil, fmt.Errorf("%s: %v", relpath, err)
+		}
+		policy := file.OnConflict
+		if policy == "" {
+			policy = g.OnConflict
+		}
+		if policy == "" {
+			policy = "fail"
+		}
+		r.Files = append(r.Files, &File{relpath, content, file.Templates, policy, hooks})
+	}
+	sort.Slice(r.Files, func(i, j int) bool { return r.Files[i].Path < r.Files[j].Path })
+	sort.Strings(r.Skipped)
+	warnings.add(ts.Warnings())
+	r.Warnings = warnings.list
+	return r, nil
+}
+
+// Warnings collected from several template sets, each listed once.
+type warningList struct {
+	list []string
+	seen map[string]bool
+}
+
+func newWarnings(ws []string) *warningList {
+	w := &warningList{seen: make(map[string]bool)}
+	w.add(ws)
+	return w
+}
+
+func (w *warningList) add(ws []string) {
+	for _, warning := range ws {
+		if !w.seen[warning] {
+			w.seen[warning] = true
+			w.list = append(w.list, warning)
+		}
+	}
+}
+
+// Format rendered file content according to mode (see config.FormatModes).
+// The imports mode groups the packages of module separately.
+func formatFile(mode string, content []byte, module string) ([]byte, error) {
+	switch mode {
+	case "gofmt":
+		return gosrc.Format(content)
+	case "imports":
+		return gosrc.Imports(content, module)
+	case "none":
+		return content, nil
+	}
+	return nil, fmt.Errorf("unknown format: %q", mode)
+}
+
+// Describe the template that rendered line (counting from 1) of content,
+// which is the concatenated output of templates. Starts holds the offset in
+// content where the output of each template begins.
+func templateLine(content []byte, templates []string, starts []int, line int) string {
+	i := len(starts) - 1
+	for i > 0 && bytes.Count(content[:starts[i]], []byte("\n")) >= line {
+		i--
+	}
+	if i < 0 {
+		return "no template"
+	}
+	first := bytes.Count(content[:starts[i]], []byte("\n"))
+	return fmt.Sprintf("%s, line %d of its output", templates[i], line-first)
+}
+
+// The import declaration for imports written as for gosrc.ParseImport. Files
+// collect imports into one declaration instead (see gosrc.ImportSet).
+func importDecl(specs ...string) (string, error) {
+	imports := new(gosrc.ImportSet)
+	if err := imports.Add(specs...); err != nil {
+		return "", err
+	}
+	return imports.Decl(), nil
+}
+
+// The template functions for env. Times are those of now.
+func funcs(env *config.Environment, now time.Time) template.FuncMap {
+	return template.FuncMap{
+		"name":  func() string { return env.User.Name },
+		"email": func() string { return env.User.Email },
+
+		"year": func() string { return now.Format("2006") },
+		"time": func(format ...string) string {
+			if len(format) == 0 {
+				format = append(format, time.RFC1123)
+			}
+			return now.Format(format[0])
+		},
+		"date": func(format ...string) string {
+			if len(format) == 0 {
+				format = append(format, "Jan 02, 2006")
+			}
+			return now.Format(format[0])
+		},
+
+		"import": importDecl,
+		"equal": func(v1, v2 interface{}) bool {
+			return reflect.DeepEqual(reflect.ValueOf(v1), reflect.ValueOf(v2))
+		},
+	}
+}
@@ -0,0 +1,50 @@
+package generator
+
+import (
+	"fmt"
+	"sort"
+
+	"github.com/bmatsuo/gonew/config"
+)
+
+// Compute the value of each parameter from the given values. Parameters
+// without a given value are prompted for when prompt is not nil and otherwise
+// take their default.
+func resolveVars(params map[string]*config.ParameterConfig, given map[string]string, prompt func(string, *config.ParameterConfig) (interface{}, error)) (map[string]interface{}, error) {
+	for name := range given {
+		if _, ok := params[name]; !ok {
+			return nil, fmt.Errorf("unknown parameter: %s", name)
+		}
+	}
+	names := make([]string, 0, len(params))
+	for name := range params {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	vars := make(map[string]interface{}, len(params))
+	for _, name := range names {
+		param := params[name]
+		s, ok := given[name]
+		if !ok && prompt != nil {
+			v, err := prompt(name, param)
+			if err != nil {
+				return nil, err
+			}
+			vars[name] = v
+			continue
+		}
+		if !ok {
+			if param.Default == "" && param.Type != config.ParameterString && param.Type != "" {
+				return nil, fmt.Errorf("missing parameter: %s", name)
+			}
+			s = param.Default
+		}
+		v, err := param.Parse(s)
+		if err != nil {
+			return nil, fmt.Errorf("parameter %s: %v", name, err)
+		}
+		vars[name] = v
+	}
+	return vars, nil
+}
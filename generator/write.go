@@ -0,0 +1,283 @@
+package generator
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/bmatsuo/gonew/config"
+	"github.com/bmatsuo/gonew/manifest"
+	"github.com/bmatsuo/gonew/project"
+	"github.com/bmatsuo/gonew/stage"
+)
+
+// Write the files of r and run its hooks. Nothing is left behind on failure.
+func (g *Generator) write(ctx context.Context, r *Result) (err error) {
+	root := r.Project.Root()
+	out := g.Output
+	var stg *stage.Stage
+	var created []string
+	defer func() {
+		if err == nil {
+			return
+		}
+		if out != nil {
+			out.Rollback()
+		}
+		for _, dir := range created {
+			os.Remove(dir)
+		}
+	}()
+	if out == nil {
+		if created, err = mkdirAll(root); err != nil {
+			return fmt.Errorf("output directory: %v", err)
+		}
+		if stg, err = stage.New(root); err != nil {
+			return fmt.Errorf("stage: %v", err)
+		}
+		stg.Conflict = func(rel string) (stage.Policy, error) {
+			return g.conflictPolicy(root, rel, r.Files)
+		}
+		out = stg
+	}
+
+	if stg != nil {
+		if err = g.executeHooks(ctx, stg, r, "pre", r.Pre); err != nil {
+			return err
+		}
+	}
+	for _, file := range r.Files {
+		if err = ctx.Err(); err != nil {
+			return err
+		}
+		rel, ok := relativePath(root, file.Path)
+		if !ok {
+			return fmt.Errorf("%s: outside of the output directory %s", file.Path, root)
+		}
+		if err = out.WriteFile(filepath.ToSlash(rel), file.Content, 0644); err != nil {
+			return fmt.Errorf("%s: %v", file.Path, err)
+		}
+		if stg != nil {
+			if err = g.executeHooks(ctx, stg, r, "file", file.Hooks); err != nil {
+				return fmt.Errorf("%s: %v", file.Path, err)
+			}
+		}
+	}
+	if stg != nil {
+		if err = g.executeHooks(ctx, stg, r, "post", r.Post); err != nil {
+			return err
+		}
+	}
+	if err = ctx.Err(); err != nil {
+		return err
+	}
+	if err = out.Commit(); err != nil {
+		return fmt.Errorf("write: %v", err)
+	}
+	if stg != nil {
+		for _, rel := range stg.Conflicts {
+			r.Conflicts = append(r.Conflicts, filepath.Join(root, filepath.FromSlash(rel)))
+		}
+	}
+	return nil
+}
+
+// Execute hooks in the staged project tree, which is output to root. Working
+// directories in root are resolved in the stage and created if they do not
+// exist. Shell commands run with bash and others run their program directly,
+// with the environment of hookEnv. Each command run is recorded in r.Hooks.
+func (g *Generator) executeHooks(ctx context.Context, stg *stage.Stage, r *Result, stage string, hooks []*Hook) error {
+	root := r.Project.Root()
+	for _, hook := range hooks {
+		cwd := hook.Cwd
+		if rel, ok := relativePath(root, cwd); ok {
+			var err error
+			if cwd, err = stg.Path(rel); err != nil {
+				return fmt.Errorf("hook cwd: %v", err)
+			}
+			if err := os.MkdirAll(cwd, 0755); err != nil {
+				return fmt.Errorf("hook cwd: %v", err)
+			}
+		}
+		env, err := hookEnv(stg, r.Project, hook.Env)
+		if err != nil {
+			return fmt.Errorf("hook env: %v", err)
+		}
+		for _, cmd := range hook.Commands {
+			run := g.runHook(ctx, hook, cmd, cwd, env)
+			run.Stage, run.Cwd = stage, hook.Cwd
+			r.Hooks = append(r.Hooks, run)
+			if run.Err == nil {
+				continue
+			}
+			if run.Output != nil && g.Stderr != nil {
+				g.Stderr.Write(run.Output)
+			}
+			if ctx.Err() != nil {
+				return ctx.Err()
+			}
+			if !hook.ContinueOnError {
+				return fmt.Errorf("hook %s: %v", cmd, run.Err)
+			}
+			run.Ignored = true
+			break
+		}
+	}
+	return nil
+}
+
+// How long a hook command's output may stay open after it exits or is killed.
+const hookWaitDelay = time.Second
+
+// Run a command of hook in cwd. The command and the processes it started are
+// killed when ctx is done or when it outlives the hook's Timeout.
+func (g *Generator) runHook(ctx context.Context, hook *Hook, cmd config.HookCommand, cwd string, env []string) *HookRun {
+	if hook.Timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, hook.Timeout)
+		defer cancel()
+	}
+	var proc *exec.Cmd
+	if cmd.Args == nil {
+		proc = exec.CommandContext(ctx, "bash", "-c", cmd.Shell)
+	} else {
+		proc = exec.CommandContext(ctx, cmd.Args[0], cmd.Args[1:]...)
+	}
+	processGroup(proc)
+	proc.WaitDelay = hookWaitDelay
+	proc.Dir = cwd
+	proc.Env = env
+	proc.Stdin = g.Stdin
+	proc.Stdout = g.Stdout
+	proc.Stderr = g.Stderr
+	var output *bytes.Buffer
+	if hook.Quiet {
+		output = new(bytes.Buffer)
+		proc.Stdout, proc.Stderr = output, output
+	}
+
+	run := &HookRun{Command: cmd, Exit: -1}
+	start := time.Now()
+	run.Err = proc.Run()
+	run.Duration = time.Since(start)
+	if proc.ProcessState != nil {
+		run.Exit = proc.ProcessState.ExitCode()
+	}
+	if run.Err != nil && ctx.Err() == context.DeadlineExceeded {
+		run.Err = fmt.Errorf("timed out after %v", hook.Timeout)
+	}
+	if run.Err != nil && output != nil {
+		run.Output = output.Bytes()
+	}
+	return run
+}
+
+// The environment of hooks for proj: the environment of gonew, then variables
+// describing the project, then vars. GONEW_ROOT is the staged output
+// directory, where hooks see the generated files.
+func hookEnv(stg *stage.Stage, proj project.Interface, vars map[string]string) ([]string, error) {
+	root, err := filepath.Abs(stg.Dir())
+	if err != nil {
+		return nil, err
+	}
+	env := append(os.Environ(),
+		"GONEW_PROJECT_NAME="+proj.Name(),
+		"GONEW_PACKAGE="+proj.Package(),
+		"GONEW_IMPORT="+proj.Import(),
+		"GONEW_ROOT="+root,
+	)
+	names := make([]string, 0, len(vars))
+	for name := range vars {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		env = append(env, name+"="+vars[name])
+	}
+	return env, nil
+}
+
+// The policy for rel, an existing file in root. Generated files use their own
+// policy. Files created by hooks, like the contents of a .git directory, never
+// replace existing files and are skipped.
+func (g *Generator) conflictPolicy(root, rel string, files []*File) (stage.Policy, error) {
+	policy := ""
+	for _, file := range files {
+		if frel, ok := relativePath(root, file.Path); ok && filepath.ToSlash(frel) == rel {
+			policy = file.OnConflict
+		}
+	}
+	if policy == "" {
+		return stage.Skip, nil
+	}
+	if policy != "prompt" {
+		return stage.ParsePolicy(policy)
+	}
+	if g.PromptConflict == nil {
+		return "", fmt.Errorf("cannot prompt for existing file: %s", rel)
+	}
+	return g.PromptConflict(rel)
+}
+
+// Like os.MkdirAll, but returns the directories created, deepest first.
+func mkdirAll(dir string) ([]string, error) {
+	var created []string
+	for d := filepath.Clean(dir); ; d = filepath.Dir(d) {
+		if _, err := os.Stat(d); err == nil || !os.IsNotExist(err) {
+			break
+		}
+		created = append(created, d)
+		if d == filepath.Dir(d) {
+			break
+		}
+	}
+	return created, os.MkdirAll(dir, 0755)
+}
+
+// The path of target relative to root, if target is in root.
+func relativePath(root, target string) (string, bool) {
+	rel, err := filepath.Rel(root, target)
+	if err != nil || rel == ".." || strings.HasPrefix(rel, ".."+string(filepath.Separator)) {
+		return "", false
+	}
+	return rel, true
+}
+
+// Record gen, the generation of files, in the manifest of the project in root.
+// When update is true an existing manifest is updated. The manifest is
+// returned as a File to write, or nil if some file lies outside of root.
+func manifestFile(root string, update bool, gen *manifest.Generation, files []*File) (*File, error) {
+	for _, file := range files {
+		rel, ok := relativePath(root, file.Path)
+		if !ok {
+			gen.Files = nil
+			return nil, nil
+		}
+		gen.Files = append(gen.Files, &manifest.File{
+			Path:      filepath.ToSlash(rel),
+			Templates: file.Templates,
+			Hash:      manifest.Hash(file.Content),
+			Content:   string(file.Content),
+		})
+	}
+	m := new(manifest.Manifest)
+	if update {
+		var err error
+		if m, err = manifest.ReadOrEmpty(root); err != nil {
+			return nil, err
+		}
+	}
+	m.Add(gen)
+	p, err := m.Marshal()
+	if err != nil {
+		return nil, err
+	}
+	path := filepath.Join(root, manifest.Filename)
+	return &File{Path: path, Content: p, OnConflict: "overwrite"}, nil
+}
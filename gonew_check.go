@@ -0,0 +1,39 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/bmatsuo/gonew/config"
+	"github.com/bmatsuo/gonew/generator"
+)
+
+// Check the templates and project types of the config, strictly unless
+// -strict=false is given. Warnings are printed but only other problems make
+// the command fail.
+func checkCommand(opts *options, conf *config.Gonew) error {
+	if len(opts.args) != 0 {
+		return fmt.Errorf("unexpected arguments: %s", strings.Join(opts.args, " "))
+	}
+	g := newGenerator(conf, generator.Options{Environment: opts.env})
+	g.PromptVar, g.PromptConflict = nil, nil
+	if !opts.strictSet {
+		g.Strict = true
+	}
+	problems, err := g.Check(context.Background())
+	if err != nil {
+		return err
+	}
+	var errs int
+	for _, p := range problems {
+		fmt.Println(p)
+		if !p.Warning {
+			errs++
+		}
+	}
+	if errs > 0 {
+		return fmt.Errorf("%d problems found", errs)
+	}
+	return nil
+}
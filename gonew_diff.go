@@ -0,0 +1,181 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/bmatsuo/gonew/config"
+	"github.com/bmatsuo/gonew/diff"
+	"github.com/bmatsuo/gonew/generator"
+	"github.com/bmatsuo/gonew/manifest"
+	"github.com/bmatsuo/gonew/project"
+)
+
+// Directories of version control systems, which diff does not compare.
+var vcsDirs = map[string]bool{".git": true, ".hg": true, ".svn": true, ".bzr": true}
+
+// Compare a project directory with what gonew would generate for it. With a
+// project type and target the project is rendered from them. Otherwise the
+// generations in the directory's manifest are rendered again.
+func diffCommand(opts *options, conf *config.Gonew) error {
+	var dir string
+	var files []*generator.File
+	var err error
+	switch len(opts.args) {
+	case 0, 1:
+		dir = "."
+		if len(opts.args) == 1 {
+			dir = opts.args[0]
+		}
+		files, err = diffManifest(conf, dir)
+	case 2, 3:
+		files, dir, err = diffProject(opts, conf)
+	default:
+		return fmt.Errorf("unexpected arguments: %s", strings.Join(opts.args[3:], " "))
+	}
+	if err != nil {
+		return err
+	}
+
+	expected := make(map[string]bool, len(files))
+	var differ int
+	var missing, unexpected []string
+	for _, file := range files {
+		expected[file.Path] = true
+		path := filepath.Join(dir, filepath.FromSlash(file.Path))
+		disk, err := os.ReadFile(path)
+		if os.IsNotExist(err) {
+			missing = append(missing, path)
+			continue
+		} else if err != nil {
+			return err
+		}
+		if bytes.Equal(disk, file.Content) {
+			continue
+		}
+		differ++
+		if bytes.IndexByte(disk, 0) >= 0 || bytes.IndexByte(file.Content, 0) >= 0 {
+			fmt.Printf("Binary file %s differs\n", path)
+			continue
+		}
+		fmt.Print(diff.Unified(path, path+" (generated)", string(disk), string(file.Content)))
+	}
+	err = filepath.WalkDir(dir, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if d.IsDir() {
+			if path != dir && (vcsDirs[d.Name()] || strings.HasPrefix(d.Name(), ".gonew-stage-")) {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		rel, err := filepath.Rel(dir, path)
+		if err != nil {
+			return err
+		}
+		rel = filepath.ToSlash(rel)
+		if !expected[rel] && rel != manifest.Filename {
+			unexpected = append(unexpected, path)
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+	for _, path := range missing {
+		fmt.Printf("missing %s\n", path)
+	}
+	for _, path := range unexpected {
+		fmt.Printf("unexpected %s\n", path)
+	}
+	if differ+len(missing)+len(unexpected) > 0 {
+		return fmt.Errorf("%d files differ, %d missing, %d unexpected", differ, len(missing), len(unexpected))
+	}
+	return nil
+}
+
+// Render the generations in the manifest of the project in dir. File paths
+// are relative to dir.
+func diffManifest(conf *config.Gonew, dir string) ([]*generator.File, error) {
+	m, err := manifest.Read(dir)
+	if err != nil {
+		return nil, err
+	}
+	var files []*generator.File
+	for _, g := range m.Generations {
+		_, gfiles, err := regenerate(conf, g)
+		if err != nil {
+			return nil, err
+		}
+		for _, file := range gfiles {
+			if owner, _ := m.File(file.Path); owner == nil || owner == g {
+				files = append(files, file)
+			}
+		}
+	}
+	sort.Slice(files, func(i, j int) bool { return files[i].Path < files[j].Path })
+	return files, nil
+}
+
+// Render the project type and target given as arguments. File paths are
+// relative to the project directory, which is also returned. Unless -time is
+// given, a project directory with a manifest is rendered at the time of its
+// latest generation (of the project type, if any), so dated templates match.
+func diffProject(opts *options, conf *config.Gonew) ([]*generator.File, string, error) {
+	gopts := generator.Options{
+		Project:     opts.args[0],
+		Environment: opts.env,
+		Name:        opts.args[1],
+		Package:     opts.pkg,
+		Module:      opts.module,
+		Root:        opts.output,
+		Vars:        opts.vars,
+		OnConflict:  "overwrite",
+	}
+	var dir string
+	if len(opts.args) == 3 {
+		dir = opts.args[2]
+	} else {
+		dir = project.New(gopts.Name, "", nil, project.WithRoot(gopts.Root)).Prefix()
+	}
+	if fixedTime.IsZero() {
+		m, err := manifest.ReadOrEmpty(dir)
+		if err != nil {
+			return nil, "", err
+		}
+		if g := latestGeneration(m, gopts.Project); g != nil {
+			gopts.Time = g.Time
+		}
+	}
+	r, err := newGenerator(conf, gopts).Render(context.Background())
+	if err != nil {
+		return nil, "", err
+	}
+	printWarnings(r)
+	files, err := r.ProjectFiles()
+	if err != nil {
+		return nil, "", err
+	}
+	return files, dir, nil
+}
+
+// The latest generation in m of the project type project, or the latest
+// generation of any type. Nil if m has no generations.
+func latestGeneration(m *manifest.Manifest, project string) *manifest.Generation {
+	for i := len(m.Generations) - 1; i >= 0; i-- {
+		if m.Generations[i].Project == project {
+			return m.Generations[i]
+		}
+	}
+	if n := len(m.Generations); n > 0 {
+		return m.Generations[n-1]
+	}
+	return nil
+}
@@ -0,0 +1,69 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"github.com/bmatsuo/gonew/config"
+	"github.com/bmatsuo/gonew/generator"
+	"github.com/bmatsuo/gonew/gonewtest"
+)
+
+// Compare project types with their golden directories in the -golden
+// directory, or update them with -update. Without arguments every project
+// type with a golden directory is tested.
+func testCommand(opts *options, conf *config.Gonew) error {
+	projects := opts.args
+	if len(projects) == 0 {
+		for _, name := range sortedKeys(conf.Projects) {
+			if info, err := os.Stat(filepath.Join(opts.golden, name)); err == nil && info.IsDir() {
+				projects = append(projects, name)
+			}
+		}
+		if len(projects) == 0 {
+			return fmt.Errorf("no golden directories in %s", opts.golden)
+		}
+	}
+	g := newGenerator(conf, generator.Options{})
+	g.PromptVar, g.PromptConflict = nil, nil
+	compare := gonewtest.Compare
+	if opts.update {
+		compare = gonewtest.Update
+	}
+	var failed int
+	for _, name := range projects {
+		r, err := compare(context.Background(), g, &gonewtest.Case{
+			Project:     name,
+			Environment: opts.env,
+			Package:     opts.pkg,
+			Module:      opts.module,
+			Vars:        opts.vars,
+			Time:        fixedTime,
+			Golden:      filepath.Join(opts.golden, name),
+		})
+		switch {
+		case err != nil:
+			failed++
+			fmt.Printf("FAIL %s: %v\n", name, err)
+		case opts.update && !r.OK():
+			fmt.Printf("updated %s\n", name)
+		case !r.OK():
+			failed++
+			fmt.Print(r)
+			fmt.Printf("FAIL %s: %v\n", name, r.Err())
+		default:
+			fmt.Printf("ok %s\n", name)
+		}
+		if r != nil {
+			for _, warning := range r.Warnings {
+				fmt.Printf("warning: %s: %s\n", name, warning)
+			}
+		}
+	}
+	if failed > 0 {
+		return fmt.Errorf("%d of %d project types failed", failed, len(projects))
+	}
+	return nil
+}
@@ -6,28 +6,58 @@
 Gonew generates new Go projects. The produced projects contain stub files and
 can optionally initialize repositories and add files to them.
 
-Usage
+# Usage
 
-    gonew [options] project target
+	gonew [options] project target
+	gonew [options] list
+	gonew [options] show project
+	gonew [options] upgrade [dir]
+	gonew [options] diff [project target] [dir]
+	gonew [options] check
+	gonew [options] test [project ...]
 
-Arguments
+# Arguments
 
 	project: The type of project to generate
 	target: The name from which filenames are based
 
-Options
+# Options
 
 	-config="": specify config path
+	-n, -dry-run: print what would be generated without writing files or running hooks
+	-manifest=true: record the generation in the project's .gonew.json
+	-on-conflict="fail": handling of existing files (fail, skip, overwrite, backup, prompt, merge)
 	-env="": specify a user environment
 	-pkg="": specify a package name
-
-Examples
-
-    gonew pkg go-mp3lib
-    gonew -pkg mp3lib lib decode
-    gonew cmdtest goplay
-
-Configuration
+	-module="": specify a module path (default: base import path + package)
+	-o="": generate the project in a directory (default: the current directory)
+	-archive="": write the project to a .tar.gz or .zip archive, or - for stdout
+	-archive-format="": the archive format, tar.gz or zip (default: by file extension)
+	-var key=value: set a project parameter (repeatable)
+	-root="": read templates and the example config from a gonew source directory
+	-strict: fail templates using missing map keys or fields (default true for check)
+	-golden="testdata": the directory holding golden directories for test
+	-update: with test, update the golden directories instead of comparing
+	-time="": generate at a time (RFC 3339, 2006-01-02 or Unix seconds; default: $SOURCE_DATE_EPOCH or now)
+
+# Commands
+
+	list: list the configured environments and project types
+	show: print a merged project type and the project each value comes from
+	upgrade: merge changes to the config and templates into a generated project
+	diff: compare a project directory with what gonew would generate
+	check: report problems with the templates and project types
+	test: compare project types with their golden directories
+
+# Examples
+
+	gonew pkg go-mp3lib
+	gonew -pkg mp3lib lib decode
+	gonew cmdtest goplay
+	gonew -module example.com/mp3 pkg mp3
+	gonew show cmdtest
+
+# Configuration
 
 Gonew is configured via a JSON file stored in ~/.config/gonew.json. An example
 can be found in gonew.json.example The configuration file specifies
@@ -38,14 +68,236 @@ contained in a project and script hooks to execute on file creation.
 Environments can inherit/override other environments and projects can
 inherit/override from other projects.
 
-Custom Templates
+File paths and hook working directories in the configuration are relative to
+the output directory, which is the current directory unless given with the -o
+option. It is created if necessary. Templates can use it as {{.Root}} and the
+project directory within it as {{.Prefix}}.
+
+	gonew -o ~/src pkg mp3lib
+
+Projects are generated atomically. Files are written to a temporary directory
+where the hooks also run. The result is moved into place only when every file
+and hook has succeeded. On failure nothing is left behind.
+
+# Go Modules
+
+Projects are Go modules. The module path is given with the -module option and
+otherwise is the environment's BaseImportPath joined with the package name.
+Templates can use it as {{.Project.Module}}. The standard "go.mod.t2" template
+writes a go.mod file, with a go directive when the environment has a
+GoVersion. The example pkg and cmd projects inherit it from the "gomod"
+project.
+
+# Project Parameters
+
+A project can declare Parameters, values the user supplies for its templates.
+Each parameter has a Type ("string", "bool", "int" or "choice"), and
+optionally a Default, a Description, a regular expression Pattern its values
+must match and, for "choice" parameters, the allowed Choices.
+
+	"Parameters": {
+		"port": {"Type": "int", "Default": "8080", "Description": "HTTP port"},
+		"http": {"Type": "bool", "Description": "serve HTTP?"}
+	}
+
+Values are given with -var options. Gonew prompts for missing values when run
+in a terminal and otherwise uses their defaults. Templates see the typed
+values under .Vars (e.g. {{if .Vars.http}}:{{.Vars.port}}{{end}}).
+
+	gonew -var http=true -var port=80 cmd mysrv
+
+# Conditional Files
+
+A file can have a When template, rendered against the project. The file is
+skipped when it renders blank, "false", "0" or "<no value>". This lets one
+project type produce optional files.
+
+	"Doc": {
+		"Path": "{{.Project.Name}}/doc.go",
+		"Type": "go",
+		"Templates": ["go.doc.t2"],
+		"When": "{{.Vars.doc}}"
+	}
+
+# Formatting
+
+Rendered files with Type "go" are formatted with gofmt before they are
+written. A file's Format setting chooses how it is formatted: "gofmt",
+"imports" (gofmt after merging the file's imports into one declaration, with
+the standard library first and other packages after a blank line, each group
+sorted) or "none" to write the file as rendered. A syntax error in a rendered
+Go file stops generation with the file, the position and the template that
+rendered the line.
+
+	"Main": {
+		"Path": "{{.Project.Name}}/{{.Project.Name}}.go",
+		"Type": "go",
+		"Templates": ["go.cmd.t2"],
+		"Format": "imports"
+	}
+
+# Hooks
+
+A hook runs its commands in its working directory (Cwd). A command given as a
+string is a command line run with bash. A command given as an array is a
+program and its arguments, which is run directly; no shell is needed and
+arguments need no quoting. Every argument, like a command line, is a
+template.
+
+	"Post": [{
+	    "Cwd": "{{.Project.Name}}",
+	    "Env": {"GOFLAGS": "-mod=mod"},
+	    "Commands": [
+	        "go mod tidy && go vet ./...",
+	        ["git", "init"],
+	        ["git", "add", "."],
+	        ["git", "commit", "-m", "{{.Project.Name}} created by gonew"]
+	    ]
+	}]
+
+Hook processes inherit the environment of gonew with the variables of Env
+(also templates) added. They also get GONEW_PROJECT_NAME, GONEW_PACKAGE,
+GONEW_IMPORT and GONEW_ROOT, the staged output directory holding the
+generated files.
+
+Files can have hooks too. They run right after the file is written, and
+their templates see the file as {{.File.Path}} (relative to the output
+directory, like hook working directories), {{.File.Name}} and {{.File.Type}}.
+A file inherited from another project keeps its hooks unless it sets its own.
+
+	"Script": {
+	    "Path": "{{.Project.Name}}/run.sh",
+	    "Type": "other",
+	    "Templates": ["run.sh.t2"],
+	    "Hooks": [{"Commands": [["chmod", "+x", "{{.File.Path}}"]]}]
+	}
+
+A failed command fails the generation unless the hook sets ContinueOnError,
+in which case only the rest of the hook's commands are skipped. A hook's
+Timeout (e.g. "30s") limits how long each of its commands may run. Output of
+a Quiet hook is only shown when a command fails. Interrupting gonew kills the
+running command and nothing is written. After running hooks gonew reports
+the exit status and duration of each command.
+
+# Existing Projects
+
+By default gonew refuses to replace existing files. To add a project type to
+an existing directory choose what happens to files that exist, either for all
+files with the -on-conflict option or for one file with its OnConflict
+setting. The policies are
+
+	fail: stop without writing anything (the default)
+	skip: keep the existing file
+	overwrite: replace the existing file
+	backup: rename the existing file with a .bak suffix, then replace it
+	merge: keep the lines both versions share and mark the differences
+	       with conflict markers
+	prompt: ask which of the above to use
+
+Files merged with conflicting lines are listed and gonew exits with an
+error, leaving the conflict markers to resolve. Files created by hooks never
+replace existing files, so generating into an existing repository keeps its
+history.
+
+A dry run (-n) marks the files that exist with their policy and exits with
+an error if any would fail the generation.
+
+For example, to add a .travis.yml to an existing repository or change its
+license
+
+	gonew travis myproj
+	gonew -on-conflict overwrite mit myproj
+
+# Archives
+
+With the -archive option gonew writes the project to a tar.gz or zip archive
+instead of the file system, e.g. to offer a project skeleton for download.
+The format is chosen by the file's extension or by -archive-format. An archive
+named "-" is written to stdout. Hooks are not run for archives, and the
+archive's manifest ignores any manifest on disk.
+
+	gonew -archive mp3lib.zip pkg mp3lib
+	gonew -archive - -archive-format zip pkg mp3lib > mp3lib.zip
+
+# Manifests
+
+Gonew records how a project was generated in a .gonew.json file in the project
+directory. The manifest lists the project type, environment, parameters, gonew
+version, template sources, generation time and the content and hash of each
+generated file. When more project types are generated into the project
+they are added to the manifest. Projects with files outside of the project
+directory have no manifest. The -manifest=false option disables manifests.
+
+# Upgrading Projects
+
+The upgrade command brings a generated project up to date with the current
+config and templates, e.g. to roll out a fix to the .gitignore or license
+templates. Each generation in the project's manifest is rendered again with
+its recorded project type, environment and parameters. The new rendering is
+merged with the file in the project using the rendering recorded in the
+manifest as the common ancestor, so the local edits and the template changes
+are both kept. Where both changed the same lines gonew writes conflict markers
+and exits with an error.
+
+	cd myproj && gonew upgrade
+	gonew -n upgrade myproj
+
+Each file is reported as untouched, added (new in the project type), updated,
+conflict, deleted (deleted locally, and left deleted) or removed (no longer
+part of the project type, but left in place). Hooks are not run.
+
+# Comparing Projects
+
+The diff command prints the differences between a project directory and what
+gonew would generate for it, as a unified diff from the directory to the
+generated files. Generated files that the directory lacks are listed as
+missing and other files in the directory as unexpected (version control
+directories are ignored). The command exits with an error when there are
+differences, so it can check a project for drift from its templates in CI.
+
+Given a project type and target the project is rendered from them, with the
+usual -env, -pkg, -module and -var options, and compared with the target's
+directory or dir. Otherwise the project's manifest is rendered again, as by
+the upgrade command, and compared with the current directory or dir.
+
+	gonew diff pkg mp3lib
+	gonew diff mp3lib
+
+# Custom Templates
 
 Users can define their own set of custom templates. This is done by adding
 entries to the ExternalTemplates array in the configuration file. Templates
 can make use of the standard gonew templates (in the "templates" directory).
 Templates must have the .t2 file extension to be recognized by Gonew.
 
-Template Functions
+Each template directory is a namespace. The standard templates are in "std"
+and an external directory's namespace is its base name, unless the entry
+names it as "name=/path/to/dir". A template can always be named in its
+namespace, as in "std/go.pkg.t2". An unqualified name refers to the
+template of the first external directory defining it, or else the standard
+one, also when templates include each other. So an external "go._head.t2"
+changes the header of every standard Go template.
+
+A template overriding another can render it with {{extend .}}. Gonew warns
+when a file uses an unqualified name defined in several namespaces, unless
+the template used extends the others. With the configuration
+
+	"ExternalTemplates": ["acme=/home/me/gonew/templates"]
+
+this README.md.t2 in /home/me/gonew/templates adds a line to the standard
+README.
+
+	{{extend .}}
+	Maintained by the Acme Corp. platform team.
+
+The standard templates and the example configuration are compiled into the
+gonew binary, so gonew does not need its source tree at run time. To use the
+files from a source checkout instead (e.g. while editing the standard
+templates) give its location with the -root option.
+
+	gonew -root $GOPATH/src/github.com/bmatsuo/gonew pkg go-mp3lib
+
+# Template Functions
 
 Templates in Gonew have acces to a small library of helper functions Here is
 list of all available template functions.
@@ -53,6 +305,88 @@ list of all available template functions.
 	name: the user's name specified in the environment
 	email: the user's email specified in the environment
 	year: the year in 4-digit format
+	import: add imports to the file's import declaration
+
+The import function takes imports as they are written in an import
+declaration, with the path optionally unquoted and optionally preceded by an
+alias, "_" or ".". It can be called from any of the templates rendered for a
+file, including nested ones. The first call marks where the declaration goes;
+it holds every import added for the file, without duplicates. The imports are
+grouped into the standard library, other packages and the packages of the
+project's module, and each group is sorted.
+
+	{{ import "fmt" "str strings" `_ "embed"` }}
+	{{ import (print .Project.Module "/internal/util") }}
+
+# Strict Templates
+
+By default templates see their context as a map, and a missing key, like a
+mistyped parameter in {{.Vars.Dcos}} or field in {{.File.Nmae}}, renders as
+"<no value>". With the -strict option templates see a typed context instead
+and a missing key or field fails rendering. Before a template executes its
+field accesses are checked against the context, including those in branches
+that would not execute. A typo in {{.Env.User.Nmae}} fails even inside an
+{{if}} that is false. Strict templates cannot index the context itself, as
+in {{index . "Env"}}. The check command is strict unless given -strict=false.
+
+	gonew -strict pkg mp3lib
+
+# Checking Templates
+
+The check command looks for mistakes in the templates and project types
+before anyone generates a project with them. Every template of the standard
+and external sources is parsed on its own and parse errors are reported with
+their file and line. The command reports templates used with {{template}} or
+named in a file's Templates that no source defines, and {{extend .}} in a
+template that overrides nothing. Each project type is then rendered for a
+target named "example", with sample values for parameters without a default,
+to catch errors executing its templates. Templates of external sources that
+nothing uses are reported as warnings. The command exits with an error when
+there are problems other than warnings.
+
+	gonew check
+	gonew -env work check
+
+# Testing Templates
+
+The test command renders project types in memory and compares them with
+golden directories, catching unintended changes to a template set. The golden
+directory of a project type is named for it in the -golden directory
+("testdata" by default) and holds the files expected in the output directory.
+Projects are rendered for a target named "example" without a manifest, at
+2006-01-02 15:04:05 UTC unless a time is given (see Reproducible Output). Any
+-env, -pkg, -module and -var options are used. Without arguments every
+project type with a golden directory is tested. Differences are printed as by
+the diff command and the command exits with an error. The -update option
+writes the rendered files to the golden directories instead, creating them as
+needed.
+
+	cd ~/src/my-templates
+	gonew -update test pkg cmd
+	gonew test
+
+Go tests can do the same with the gonewtest package.
+
+# Reproducible Output
+
+Templates read the time from one clock: the year, date and time functions,
+{{.X.Time}}, the time recorded in the manifest and the times of archive
+entries all agree. The clock is the current time unless the -time option or
+a SOURCE_DATE_EPOCH environment variable (seconds since the Unix epoch)
+fixes it, so the same config and templates generate byte-identical
+projects and archives. The upgrade and diff commands render each
+generation at the time in its manifest, and diff given a project type renders
+it at the time of the directory's latest generation, unless the time is
+fixed. So they only report changes to the config and templates.
+
+	gonew -time 2024-01-01 -archive mp3lib.zip pkg mp3lib
+	SOURCE_DATE_EPOCH=1704067200 gonew pkg mp3lib
+
+# Using Gonew from Go
+
+The generator package does the work of the gonew command. Programs and tests
+can import it to generate projects in-process; errors are returned to the
+caller instead of ending the program.
 */
 package main
 
@@ -62,39 +396,106 @@ package main
  */
 import (
 	"github.com/bmatsuo/gonew/config"
-	"github.com/bmatsuo/gonew/project"
+	"github.com/bmatsuo/gonew/generator"
+	"github.com/bmatsuo/gonew/manifest"
+	"github.com/bmatsuo/gonew/output"
+	"github.com/bmatsuo/gonew/stage"
 	"github.com/bmatsuo/gonew/templates"
 
 	"bufio"
 	"bytes"
+	"context"
+	_ "embed"
 	"encoding/json"
+	"errors"
 	"flag"
 	"fmt"
+	"io"
 	"os"
-	"os/exec"
+	"os/signal"
 	"path/filepath"
-	"reflect"
+	"strconv"
 	"strings"
 	"syscall"
-	"text/template"
+	"text/tabwriter"
 	"time"
 	"unicode"
 )
 
-// The directory containing Gonew's source code.
-var GonewRoot string // The Gonew source directory
-func FindGonew() error {
-	gopath := os.Getenv("GOPATH")
-	if gopath == "" {
-		return fmt.Errorf("GOPATH is not set")
+// The version of gonew, recorded in project manifests.
+const Version = "2.1.0"
+
+// The example configuration, used to bootstrap new config files.
+//
+//go:embed gonew.json.example
+var exampleConfig []byte
+
+// An optional directory with Gonew's source layout. When set, its "templates"
+// directory and "gonew.json.example" are used instead of the built-in files.
+var GonewRoot string
+
+// Render templates strictly (see generator.Options).
+var strictTemplates bool
+
+// The time of generation given with -time or SOURCE_DATE_EPOCH, or zero.
+var fixedTime time.Time
+
+// The time projects are generated at, which templates see and archives and
+// manifests record.
+func generationTime() time.Time {
+	if fixedTime.IsZero() {
+		return time.Now()
 	}
-	gopath = strings.SplitN(gopath, ":", 2)[0]
-	GonewRoot = filepath.Join(gopath, "src", "github.com", "bmatsuo", "gonew")
-	stat, err := os.Stat(GonewRoot)
-	if err == nil && !stat.IsDir() {
-		err = fmt.Errorf("file is not a directory: %s", GonewRoot)
+	return fixedTime
+}
+
+// Parse the -time option, or else a SOURCE_DATE_EPOCH environment variable.
+// Times are RFC 3339 times, dates like 2006-01-02 (UTC), or seconds since
+// the Unix epoch (UTC). Neither gives the zero time.
+func parseTime(option, epoch string) (time.Time, error) {
+	if option == "" && epoch == "" {
+		return time.Time{}, nil
 	}
-	return err
+	if option == "" {
+		sec, err := strconv.ParseInt(epoch, 10, 64)
+		if err != nil {
+			return time.Time{}, fmt.Errorf("invalid SOURCE_DATE_EPOCH: %q", epoch)
+		}
+		return time.Unix(sec, 0).UTC(), nil
+	}
+	if sec, err := strconv.ParseInt(option, 10, 64); err == nil {
+		return time.Unix(sec, 0).UTC(), nil
+	}
+	for _, layout := range []string{time.RFC3339, "2006-01-02"} {
+		if t, err := time.Parse(layout, option); err == nil {
+			return t, nil
+		}
+	}
+	return time.Time{}, fmt.Errorf("invalid time: %q (use RFC 3339, 2006-01-02 or Unix seconds)", option)
+}
+
+// The standard template source.
+func standardTemplates() interface{} {
+	if GonewRoot == "" {
+		return templates.Standard()
+	}
+	return templates.SourceDirectory(filepath.Join(GonewRoot, "templates"))
+}
+
+// How manifests record the standard template source.
+func standardName() string {
+	if GonewRoot == "" {
+		return "builtin"
+	}
+	return filepath.Join(GonewRoot, "templates")
+}
+
+// Read the example configuration into conf.
+func readExampleConfig(conf *config.Gonew) error {
+	if GonewRoot == "" {
+		return conf.UnmarshalReaderJSON(bytes.NewReader(exampleConfig))
+	}
+	return conf.UnmarshalFileJSON(filepath.Join(GonewRoot, "gonew.json.example"))
 }
 
 func check(err error, v ...interface{}) error {
@@ -102,7 +503,7 @@ func check(err error, v ...interface{}) error {
 		if len(v) == 0 {
 			fmt.Println(err)
 		} else {
-			fmt.Printf("%s: %v", fmt.Sprint(v...), err)
+			fmt.Printf("%s: %v\n", fmt.Sprint(v...), err)
 		}
 	}
 	return err
@@ -122,88 +523,162 @@ func logJson(v ...interface{}) {
 	fmt.Println(w...)
 }
 
-func executeHooks(ts templates.Interface, tenv templates.Environment, hooks ...*config.HookConfig) {
-	for _, hook := range hooks {
-		cwd, err := tenv.RenderTextAsString(ts, "cwd_", hook.Cwd)
-		checkFatal(err, "hook cwd template")
-		// fmt.Println("cd", cwd)
-		for _, _cmd := range hook.Commands {
-			cmd, err := tenv.RenderTextAsString(ts, "cmd_", _cmd)
-			checkFatal(err, "hook template")
-			// fmt.Println("bash", "-c", cmd)
-			shell := exec.Command("bash", "-c", cmd)
-			shell.Dir = cwd
-			shell.Stdin = os.Stdin
-			shell.Stdout = os.Stdout
-			shell.Stderr = os.Stderr
-			checkFatal(shell.Run(), "hook") // TODO clean exit
+// Ask the user how to handle rel, an existing file whose policy is "prompt".
+func promptConflict(rel string) (stage.Policy, error) {
+	for {
+		line, err := readLine(stdin, rel+" exists: [f]ail, [s]kip, [o]verwrite, [b]ackup or [m]erge? ")
+		if err != nil {
+			return "", err
+		}
+		for _, p := range []stage.Policy{stage.Fail, stage.Skip, stage.Overwrite, stage.Backup, stage.Merge} {
+			if line != "" && strings.HasPrefix(string(p), strings.ToLower(line)) {
+				return p, nil
+			}
 		}
 	}
 }
 
-type File struct {
-	path    string
-	content []byte
-}
-
-func funcs(env *config.Environment) template.FuncMap {
-	return template.FuncMap{
-		"name":  func() string { return env.User.Name },
-		"email": func() string { return env.User.Email },
-
-		"year": func() string { return time.Now().Format("2006") },
-		"time": func(format ...string) string {
-			if len(format) == 0 {
-				format = append(format, time.RFC1123)
+// Print the hooks and files that would be generated, without executing or
+// writing anything. Files omitted by a When expression are listed as skipped.
+// Returns the number of existing files whose policy would fail generation.
+func printPlan(w io.Writer, r *generator.Result) (failing int) {
+	printHooks := func(stage string, hooks []*generator.Hook) {
+		for _, hook := range hooks {
+			cwd := hook.Cwd
+			if cwd == "" {
+				cwd = "."
 			}
-			return time.Now().Format(format[0])
-		},
-		"date": func(format ...string) string {
-			if len(format) == 0 {
-				format = append(format, "Jan 02, 2006")
+			for _, cmd := range hook.Commands {
+				fmt.Fprintf(w, "hook %s (cwd %s): %s\n", stage, cwd, cmd)
 			}
-			return time.Now().Format(format[0])
-		},
-
-		"import": func(pkgs ...string) string {
-			if len(pkgs) == 0 {
-				return `import ()`
-			}
-			if len(pkgs) == 1 {
-				return `import "` + pkgs[0] + `"`
+		}
+	}
+	printHooks("pre", r.Pre)
+	for _, file := range r.Files {
+		var exists string
+		if _, err := os.Lstat(file.Path); err == nil {
+			exists = fmt.Sprintf(" [exists, %s]", file.OnConflict)
+			if file.OnConflict == "fail" {
+				failing++
 			}
-			s := "import (\n"
-			for _, pkg := range pkgs {
-				s += "\t" + pkg + "\n"
+		}
+		source := ": " + strings.Join(file.Templates, ", ")
+		if len(file.Templates) == 0 {
+			source = ""
+			if filepath.Base(file.Path) == manifest.Filename {
+				source = ": manifest"
 			}
-			s += ")"
-			return s
-		},
-		"equal": func(v1, v2 interface{}) bool {
-			return reflect.DeepEqual(reflect.ValueOf(v1), reflect.ValueOf(v2))
-		},
+		}
+		fmt.Fprintf(w, "file %s (%d bytes)%s%s\n", file.Path, len(file.Content), exists, source)
+		printHooks("file", file.Hooks)
+	}
+	for _, name := range r.Skipped {
+		fmt.Fprintf(w, "skip %s\n", name)
+	}
+	printHooks("post", r.Post)
+	return failing
+}
+
+// Print the warnings of r, if any, to stderr.
+func printWarnings(r *generator.Result) {
+	if r == nil {
+		return
+	}
+	for _, warning := range r.Warnings {
+		fmt.Fprintln(os.Stderr, "warning:", warning)
+	}
+}
+
+// Print the exit status and duration of each hook command run.
+func printHookSummary(w io.Writer, runs []*generator.HookRun) {
+	tw := tabwriter.NewWriter(w, 0, 8, 2, ' ', 0)
+	fmt.Fprintln(tw, "hooks:")
+	for _, run := range runs {
+		status := fmt.Sprintf("exit %d", run.Exit)
+		if run.Exit < 0 && run.Err != nil {
+			status = run.Err.Error()
+		}
+		if run.Ignored {
+			status += " (ignored)"
+		}
+		fmt.Fprintf(tw, "  %s\t%s\t%v\t%s\n", run.Stage, status, run.Duration.Round(time.Millisecond), run.Command)
 	}
+	tw.Flush()
+}
+
+// Subcommands that inspect the config or operate on a generated project instead
+// of generating one.
+var commands = map[string]func(opts *options, conf *config.Gonew) error{
+	"check":   checkCommand,
+	"diff":    diffCommand,
+	"list":    listCommand,
+	"show":    showCommand,
+	"test":    testCommand,
+	"upgrade": upgradeCommand,
 }
 
 type options struct {
-	env     string
-	project string
-	target  string
-	pkg     string
-	config  string
+	command       string   // a key in commands, or empty to generate a project
+	args          []string // command arguments
+	env           string
+	project       string
+	target        string
+	pkg           string
+	module        string
+	output        string
+	archive       string
+	archiveFormat string
+	vars          varFlags
+	config        string
+	dryRun        bool
+	onConflict    string
+	manifest      bool
+	strictSet     bool // -strict was given, as check is strict by default
+	golden        string
+	update        bool
 }
 
 func parseOptions() *options {
-	opts := new(options)
+	opts := &options{vars: make(varFlags)}
 	fs := flag.NewFlagSet(os.Args[0], flag.ExitOnError)
 	fs.StringVar(&opts.env, "env", "", "specify a user environment")
 	fs.StringVar(&opts.pkg, "pkg", "", "specify a package name")
+	fs.StringVar(&opts.module, "module", "", "specify a module path")
+	fs.StringVar(&opts.output, "o", "", "generate the project in `dir` (default: the current directory)")
+	fs.StringVar(&opts.archive, "archive", "", "write the project to an archive `file` (- for stdout) instead of the file system")
+	fs.StringVar(&opts.archiveFormat, "archive-format", "", "the archive format: tar.gz or zip (default: by the -archive extension)")
+	fs.Var(opts.vars, "var", "set a project parameter (key=value, repeatable)")
 	fs.StringVar(&opts.config, "config", "", "specify config path")
+	fs.BoolVar(&opts.dryRun, "n", false, "print the generation plan without writing files or running hooks")
+	fs.BoolVar(&opts.dryRun, "dry-run", false, "same as -n")
+	fs.StringVar(&opts.onConflict, "on-conflict", "fail", "handling of existing files: "+strings.Join(config.ConflictPolicies, ", "))
+	fs.BoolVar(&opts.manifest, "manifest", true, "record the generation in the project's "+manifest.Filename)
+	fs.StringVar(&GonewRoot, "root", "", "read templates and the example config from a gonew source directory")
+	fs.BoolVar(&strictTemplates, "strict", false, "fail templates using missing map keys or fields (default true for check)")
+	fs.StringVar(&opts.golden, "golden", "testdata", "the `dir` holding golden directories for test")
+	fs.BoolVar(&opts.update, "update", false, "with test, update the golden directories instead of comparing")
+	timeOption := fs.String("time", "", "generate projects at `time` (RFC 3339, 2006-01-02 or Unix seconds; default: $SOURCE_DATE_EPOCH or now)")
 	fs.Parse(os.Args[1:])
+	fs.Visit(func(f *flag.Flag) { opts.strictSet = opts.strictSet || f.Name == "strict" })
+	var err error
+	fixedTime, err = parseTime(*timeOption, os.Getenv("SOURCE_DATE_EPOCH"))
+	checkFatal(err, "time")
 
 	args := fs.Args()
+	if len(args) > 0 {
+		if _, ok := commands[args[0]]; ok {
+			opts.command, opts.args = args[0], args[1:]
+			return opts
+		}
+	}
 	if len(args) < 1 {
 		fmt.Fprintln(os.Stderr, "usage:", os.Args[0], "[options] [project] target")
+		fmt.Fprintln(os.Stderr, "       "+os.Args[0], "[options] list")
+		fmt.Fprintln(os.Stderr, "       "+os.Args[0], "[options] show project")
+		fmt.Fprintln(os.Stderr, "       "+os.Args[0], "[options] upgrade [dir]")
+		fmt.Fprintln(os.Stderr, "       "+os.Args[0], "[options] diff [project target] [dir]")
+		fmt.Fprintln(os.Stderr, "       "+os.Args[0], "[options] check")
+		fmt.Fprintln(os.Stderr, "       "+os.Args[0], "[options] test [project ...]")
 		os.Exit(1)
 	}
 	if len(args) == 1 {
@@ -214,6 +689,7 @@ func parseOptions() *options {
 	if opts.pkg == "" {
 		opts.pkg = opts.target
 	}
+	checkFatal(config.CheckConflictPolicy(opts.onConflict), "-on-conflict")
 
 	return opts
 }
@@ -225,7 +701,10 @@ func readLine(r *bufio.Reader, prompt string) (string, error) {
 	return line, err
 }
 
-func initConfig(path string) (conf *config.Gonew, err error) {
+// Read the config at path. If no config exists the user is prompted for the
+// information needed to create one. The created config is only written to
+// disk when save is true.
+func initConfig(path string, save bool) (conf *config.Gonew, err error) {
 	if path == "" {
 		home := os.Getenv("HOME")
 		path = filepath.Join(home, ".config", "gonew.json")
@@ -250,19 +729,22 @@ func initConfig(path string) (conf *config.Gonew, err error) {
 		var name string
 		var email string
 		var baseImportPath string
-		bufr := bufio.NewReader(os.Stdin)
-		name, err = readLine(bufr, "Your name: ")
+		name, err = readLine(stdin, "Your name: ")
 		checkFatal(err)
-		email, err = readLine(bufr, "Your email: ")
+		email, err = readLine(stdin, "Your email: ")
 		checkFatal(err)
-		baseImportPath, err = readLine(bufr, "Base import path (e.g. github.com/bmatsuo): ")
+		baseImportPath, err = readLine(stdin, "Base import path (e.g. github.com/bmatsuo): ")
 		checkFatal(err)
 
-		examplePath := filepath.Join(GonewRoot, "gonew.json.example")
-		checkFatal(conf.UnmarshalFileJSON(examplePath), "example config")
+		checkFatal(readExampleConfig(conf), "example config")
+		var goVersion string
+		if example, ok := conf.Environments["default"]; ok {
+			goVersion = example.GoVersion
+		}
 		conf.Environments = config.Environments{
 			"default": &config.Environment{
 				BaseImportPath: baseImportPath,
+				GoVersion:      goVersion,
 				User: &config.EnvironmentUserConfig{
 					Name:  name,
 					Email: email,
@@ -270,103 +752,147 @@ func initConfig(path string) (conf *config.Gonew, err error) {
 			},
 		}
 		conf.Default.Environment = "default"
-		err = conf.MarshalFileJSON(path)
+		if save {
+			err = conf.MarshalFileJSON(path)
+		}
 	}
 	return
 }
 
-func main() {
-	checkFatal(FindGonew(), "root not found")
+// A generator for conf configured for the command line. The user is prompted
+// for missing parameters and conflicts when stdin is a terminal.
+func newGenerator(conf *config.Gonew, opts generator.Options) *generator.Generator {
+	opts.Stdin, opts.Stdout, opts.Stderr = os.Stdin, os.Stdout, os.Stderr
+	opts.Strict = strictTemplates
+	if !fixedTime.IsZero() {
+		opts.Time = fixedTime
+	}
+	if interactive() {
+		opts.PromptVar = promptVar
+		opts.PromptConflict = promptConflict
+	}
+	g := generator.New(conf, opts)
+	g.Version = Version
+	g.Standard = standardTemplates()
+	g.StandardName = standardName()
+	return g
+}
 
+// A backend writing an archive to path, or to stdout if path is "-". The
+// archive format is format, or is chosen by the extension of path. Archives
+// written to stdout are tar.gz by default. Entries have the time modTime.
+func newArchive(path, format string, modTime time.Time) (*output.Archive, error) {
+	if format == "" {
+		format = output.FormatOf(path)
+	}
+	if format == "" && path == "-" {
+		format = output.TarGz
+	}
+	if format != output.TarGz && format != output.Zip {
+		return nil, fmt.Errorf("unknown archive format for %s (use -archive-format %s or %s)", path, output.TarGz, output.Zip)
+	}
+	open := func() (io.WriteCloser, error) { return os.Create(path) }
+	if path == "-" {
+		open = func() (io.WriteCloser, error) { return nopCloser{os.Stdout}, nil }
+	}
+	return &output.Archive{Format: format, ModTime: modTime, Open: open}, nil
+}
+
+type nopCloser struct{ io.Writer }
+
+func (nopCloser) Close() error { return nil }
+
+// Render a manifest generation again with the current config and templates,
+// at the recorded time unless -time or SOURCE_DATE_EPOCH is given. A
+// generation recording the new rendering (without files) is returned with
+// the rendered files, whose paths are relative to the project root.
+func regenerate(conf *config.Gonew, g *manifest.Generation) (*manifest.Generation, []*generator.File, error) {
+	r, err := newGenerator(conf, generator.Options{
+		Project:     g.Project,
+		Environment: g.Environment,
+		Name:        g.Name,
+		Package:     g.Package,
+		Module:      g.Module,
+		Vars:        g.Vars,
+		OnConflict:  "overwrite",
+		Time:        g.Time,
+	}).Render(context.Background())
+	if err != nil {
+		return nil, nil, fmt.Errorf("%s: %v", g.Project, err)
+	}
+	printWarnings(r)
+	files, err := r.ProjectFiles()
+	if err != nil {
+		return nil, nil, fmt.Errorf("%s: %v", g.Project, err)
+	}
+	return r.Generation, files, nil
+}
+
+func main() {
 	// parse command line options/args
 	opts := parseOptions()
 	// read the config file
-	conf, err := initConfig(opts.config)
+	conf, err := initConfig(opts.config, !opts.dryRun)
 	checkFatal(err, "config")
 
-	// project metadata
-	projectName := opts.target
-	packageName := opts.pkg
-	envName := opts.env
-	if envName == "" {
-		envName = conf.Default.Environment
-	}
-	projType := opts.project
-	if projType == "" {
-		projType = conf.Default.Project
+	if opts.command != "" {
+		checkFatal(commands[opts.command](opts, conf), opts.command)
+		return
 	}
 
-	// initialize project
-	env, err := conf.Environment(envName)
-	checkFatal(err)
-	project.BaseImportPath = env.BaseImportPath
-	proj := project.New(projectName, packageName, env)
-	projContext := project.Context("", "", proj)
-	projTemplEnv := templates.Env(projContext)
-	projConfig, err := conf.Project(projType)
-	checkFatal(err)
-
-	// initialize template environment
-	ts := templates.New(".t2")
-	checkFatal(ts.Funcs(funcs(env)), "templates")
-
-	// read templates
-	src := templates.SourceDirectory(filepath.Join(GonewRoot, "templates"))
-	checkFatal(ts.Source(src), "templates")
-	for i := len(conf.ExternalTemplates) - 1; i >= 0; i-- {
-		src := templates.SourceDirectory(conf.ExternalTemplates[i])
-		checkFatal(ts.Source(src), "external templates")
-	}
-
-	if projConfig.Hooks != nil {
-		executeHooks(ts, projTemplEnv, projConfig.Hooks.Pre...)
-	}
-
-	// generate files. buffer all output then write.
-	files := make([]*File, 0, len(projConfig.Files))
-	for name, file := range projConfig.Files {
-		_relpath, err := projTemplEnv.RenderTextAsString(ts, "pre_", file.Path)
-		checkFatal(err, name)
-		relpath := string(_relpath)
-		filename := filepath.Base(relpath)
-		filetype := file.Type
-
-		fileContext := project.Context(filename, filetype, proj)
-		fileTemplEnv := templates.Env(fileContext)
-		fileBuf := new(bytes.Buffer)
-		for _, t := range file.Templates {
-			if nil != check(fileTemplEnv.Render(fileBuf, ts, t)) {
-				fileBuf = nil
-				break
-			}
-		}
-
-		if fileBuf != nil {
-			f := &File{relpath, fileBuf.Bytes()}
-			files = append(files, f)
-		} else {
-			// TODO clean exit
+	// all output (including the effects of hooks) is staged and only moved
+	// into place once everything has succeeded. archives are written
+	// without running hooks.
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+	defer stop()
+	genOpts := generator.Options{
+		Project:     opts.project,
+		Environment: opts.env,
+		Name:        opts.target,
+		Package:     opts.pkg,
+		Module:      opts.module,
+		Root:        opts.output,
+		Vars:        opts.vars,
+		OnConflict:  opts.onConflict,
+		Manifest:    opts.manifest,
+		DryRun:      opts.dryRun,
+		Time:        generationTime(),
+	}
+	var opened bool // the archive file is only created when it is written
+	if opts.archive != "" && !opts.dryRun {
+		archive, err := newArchive(opts.archive, opts.archiveFormat, genOpts.Time)
+		checkFatal(err, "archive")
+		open := archive.Open
+		archive.Open = func() (io.WriteCloser, error) {
+			opened = true
+			return open()
 		}
+		genOpts.Output = archive
 	}
-	for _, file := range files {
-		dir := filepath.Dir(file.path)
-
-		// fmt.Println("mkdir", "-p", dir)
-		err := os.MkdirAll(dir, 0755|os.ModeDir)
-		checkFatal(err, file) // TODO clean exit
-
-		// fmt.Println("cat", ">", file.path)
-		// fmt.Println(string(file.content))
-		writeMode := os.O_WRONLY | os.O_CREATE | os.O_EXCL // must create
-		handle, err := os.OpenFile(file.path, writeMode, 0644)
-		checkFatal(err, file) // TODO clean exit
-		_, err = handle.Write(file.content)
-		checkFatal(err, file) // TODO clean exit
-		err = handle.Close()
+	r, err := newGenerator(conf, genOpts).Generate(ctx)
+	printWarnings(r)
+	if r != nil && len(r.Hooks) > 0 {
+		printHookSummary(os.Stderr, r.Hooks)
+	}
+	if err != nil && opened && opts.archive != "-" {
+		os.Remove(opts.archive)
+	}
+	if err == context.Canceled {
+		err = errors.New("interrupted")
+	}
+	checkFatal(err)
+	for _, path := range r.Conflicts {
+		fmt.Fprintf(os.Stderr, "conflict %s\n", path)
+	}
+	if n := len(r.Conflicts); n > 0 {
+		checkFatal(fmt.Errorf("%d files have conflicts", n))
 	}
 
-	if projConfig.Hooks != nil {
-		// fmt.Println("POST")
-		executeHooks(ts, projTemplEnv, projConfig.Hooks.Post...)
+	if opts.dryRun {
+		if n := printPlan(os.Stdout, r); n > 0 {
+			checkFatal(fmt.Errorf("%d files exist and would fail the generation (see -on-conflict)", n))
+		}
+	} else if genOpts.Output != nil && r.HasHooks() {
+		fmt.Fprintln(os.Stderr, "hooks are not run when writing an archive")
 	}
 }
@@ -0,0 +1,149 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/bmatsuo/gonew/config"
+)
+
+func listCommand(opts *options, conf *config.Gonew) error {
+	if len(opts.args) != 0 {
+		return fmt.Errorf("unexpected arguments: %s", strings.Join(opts.args, " "))
+	}
+	w := tabwriter.NewWriter(os.Stdout, 0, 8, 2, ' ', 0)
+	fmt.Fprintln(w, "environments:")
+	for _, name := range sortedKeys(conf.Environments) {
+		env := conf.Environments[name]
+		fmt.Fprintf(w, "  %s\t%s\n", defaultName(name, conf.Default.Environment), inherits(env.Inherits))
+	}
+	fmt.Fprintln(w, "projects:")
+	for _, name := range sortedKeys(conf.Projects) {
+		proj := conf.Projects[name]
+		fmt.Fprintf(w, "  %s\t%s\n", defaultName(name, conf.Default.Project), inherits(proj.Inherits))
+	}
+	return w.Flush()
+}
+
+func showCommand(opts *options, conf *config.Gonew) error {
+	if len(opts.args) != 1 {
+		return fmt.Errorf("expected one project name")
+	}
+	name := opts.args[0]
+	order, err := conf.ProjectMergeOrder(name)
+	if err != nil {
+		return err
+	}
+	proj, origins, err := conf.ProjectOrigins(name)
+	if err != nil {
+		return err
+	}
+
+	w := tabwriter.NewWriter(os.Stdout, 0, 8, 2, ' ', 0)
+	fmt.Fprintf(w, "project %s\n", name)
+	fmt.Fprintf(w, "  inherits:\t%s\n", strings.Join(conf.Projects[name].Inherits, ", "))
+	fmt.Fprintf(w, "  merge order:\t%s\n", strings.Join(order, ", "))
+	fmt.Fprintln(w, "files:")
+	for _, key := range sortedKeys(proj.Files) {
+		file, origin := proj.Files[key], origins.Files[key]
+		fmt.Fprintf(w, "  %s\n", key)
+		fmt.Fprintf(w, "    path:\t%s\t(%s)\n", file.Path, origin.Path)
+		fmt.Fprintf(w, "    type:\t%s\t(%s)\n", file.Type, origin.Type)
+		fmt.Fprintf(w, "    templates:\t%s\t(%s)\n", strings.Join(file.Templates, ", "), origin.Templates)
+		if file.When != "" {
+			fmt.Fprintf(w, "    when:\t%s\t(%s)\n", file.When, origin.When)
+		}
+		if file.OnConflict != "" {
+			fmt.Fprintf(w, "    on conflict:\t%s\t(%s)\n", file.OnConflict, origin.OnConflict)
+		}
+		if file.Format != "" {
+			fmt.Fprintf(w, "    format:\t%s\t(%s)\n", file.Format, origin.Format)
+		}
+		for _, hook := range file.Hooks {
+			fmt.Fprintf(w, "    hook cwd:\t%s\t(%s)\n", hook.Cwd, origin.Hooks)
+			for _, cmd := range hook.Commands {
+				fmt.Fprintf(w, "      %s\n", cmd)
+			}
+		}
+	}
+	if len(proj.Parameters) > 0 {
+		fmt.Fprintln(w, "parameters:")
+	}
+	for _, key := range sortedKeys(proj.Parameters) {
+		param := proj.Parameters[key]
+		typ := param.Type
+		if typ == "" {
+			typ = config.ParameterString
+		}
+		if param.Type == config.ParameterChoice {
+			typ += " " + strings.Join(param.Choices, "|")
+		}
+		if param.Default != "" {
+			typ += " (default " + param.Default + ")"
+		}
+		fmt.Fprintf(w, "  %s:\t%s\t(%s)\n", key, typ, origins.Parameters[key])
+	}
+	if proj.Hooks != nil {
+		showHooks(w, "pre", proj.Hooks.Pre, origins.Pre)
+		showHooks(w, "post", proj.Hooks.Post, origins.Post)
+	}
+	return w.Flush()
+}
+
+func showHooks(w io.Writer, stage string, hooks []*config.HookConfig, origins []string) {
+	if len(hooks) == 0 {
+		return
+	}
+	fmt.Fprintf(w, "%s hooks:\n", stage)
+	for i, hook := range hooks {
+		fmt.Fprintf(w, "  cwd:\t%s\t(%s)\n", hook.Cwd, origins[i])
+		for _, cmd := range hook.Commands {
+			fmt.Fprintf(w, "    %s\n", cmd)
+		}
+	}
+}
+
+func inherits(names []string) string {
+	if len(names) == 0 {
+		return ""
+	}
+	return "inherits " + strings.Join(names, ", ")
+}
+
+func defaultName(name, def string) string {
+	if name == def {
+		return name + " (default)"
+	}
+	return name
+}
+
+// The keys of a map with string keys in sorted order.
+func sortedKeys(m interface{}) []string {
+	var keys []string
+	switch m := m.(type) {
+	case config.Environments:
+		for k := range m {
+			keys = append(keys, k)
+		}
+	case config.Projects:
+		for k := range m {
+			keys = append(keys, k)
+		}
+	case map[string]*config.ProjectFileConfig:
+		for k := range m {
+			keys = append(keys, k)
+		}
+	case map[string]*config.ParameterConfig:
+		for k := range m {
+			keys = append(keys, k)
+		}
+	default:
+		panic(fmt.Sprintf("sortedKeys: unsupported type %T", m))
+	}
+	sort.Strings(keys)
+	return keys
+}
@@ -0,0 +1,161 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/bmatsuo/gonew/config"
+	"github.com/bmatsuo/gonew/diff"
+	"github.com/bmatsuo/gonew/manifest"
+	"github.com/bmatsuo/gonew/stage"
+)
+
+// What an upgrade does to a file.
+const (
+	upgradeUntouched = "untouched" // the file is left as it is
+	upgradeAdded     = "added"     // a new file of the project type is created
+	upgradeUpdated   = "updated"   // template changes are merged into the file
+	upgradeConflict  = "conflict"  // the file has conflict markers to resolve
+	upgradeDeleted   = "deleted"   // the user deleted the file; it is not recreated
+	upgradeRemoved   = "removed"   // the project type no longer has the file
+)
+
+// Labels of the conflict markers written by an upgrade.
+var upgradeLabels = diff.Labels{Ours: "local", Base: "base", Theirs: "gonew"}
+
+// Merge generated, a file's new rendering, into the file on disk (nil if the
+// file does not exist). Rec is the file's manifest record, if any, holding
+// the content of the previous rendering. The content to write is returned
+// with the file's upgrade status. The content is nil when nothing is written.
+func upgradeFile(rec *manifest.File, disk, generated []byte) (string, []byte) {
+	switch {
+	case disk == nil && rec == nil:
+		return upgradeAdded, generated
+	case disk == nil:
+		return upgradeDeleted, nil
+	case bytes.Equal(disk, generated):
+		return upgradeUntouched, nil
+	}
+	hasBase := rec != nil && (rec.Content != "" || rec.Hash == manifest.Hash(nil))
+	if !hasBase {
+		merged, conflicts := diff.Merge2(string(disk), string(generated), upgradeLabels)
+		if conflicts > 0 {
+			return upgradeConflict, []byte(merged)
+		}
+		return upgradeUntouched, nil
+	}
+	switch base := rec.Content; {
+	case base == string(generated):
+		return upgradeUntouched, nil
+	case base == string(disk):
+		return upgradeUpdated, generated
+	}
+	merged, conflicts := diff.Merge3(rec.Content, string(disk), string(generated), upgradeLabels)
+	if conflicts > 0 {
+		return upgradeConflict, []byte(merged)
+	}
+	return upgradeUpdated, []byte(merged)
+}
+
+// Re-render each generation in the manifest of a project with the current
+// config and templates and merge the changes into the project's files.
+func upgradeCommand(opts *options, conf *config.Gonew) error {
+	dir := "."
+	switch len(opts.args) {
+	case 0:
+	case 1:
+		dir = opts.args[0]
+	default:
+		return fmt.Errorf("unexpected arguments: %s", strings.Join(opts.args[1:], " "))
+	}
+	m, err := manifest.Read(dir)
+	if err != nil {
+		return err
+	}
+
+	type write struct {
+		rel     string
+		content []byte
+	}
+	var writes []write
+	counts := make(map[string]int)
+	report := func(status, rel string) {
+		counts[status]++
+		fmt.Printf("%-9s %s\n", status, filepath.Join(dir, filepath.FromSlash(rel)))
+	}
+
+	gens := make([]*manifest.Generation, 0, len(m.Generations))
+	for _, g := range m.Generations {
+		upgraded, files, err := regenerate(conf, g)
+		if err != nil {
+			return err
+		}
+		rendered := make(map[string]bool, len(files))
+		for _, file := range files {
+			rel := file.Path
+			owner, rec := m.File(rel)
+			if owner != nil && owner != g {
+				continue // a later generation replaced the file
+			}
+			rendered[rel] = true
+			disk, err := os.ReadFile(filepath.Join(dir, filepath.FromSlash(rel)))
+			if err != nil && !os.IsNotExist(err) {
+				return err
+			}
+			status, content := upgradeFile(rec, disk, file.Content)
+			report(status, rel)
+			if content != nil {
+				writes = append(writes, write{rel, content})
+			}
+			upgraded.Files = append(upgraded.Files, &manifest.File{
+				Path:      rel,
+				Templates: file.Templates,
+				Hash:      manifest.Hash(file.Content),
+				Content:   string(file.Content),
+			})
+		}
+		for _, rec := range g.Files {
+			if !rendered[rec.Path] {
+				report(upgradeRemoved, rec.Path)
+			}
+		}
+		if len(upgraded.Files) > 0 {
+			gens = append(gens, upgraded)
+		}
+	}
+	m.Generations = gens
+
+	fmt.Printf("%d untouched, %d added, %d updated, %d conflicted, %d deleted, %d removed\n",
+		counts[upgradeUntouched], counts[upgradeAdded], counts[upgradeUpdated],
+		counts[upgradeConflict], counts[upgradeDeleted], counts[upgradeRemoved])
+	if opts.dryRun {
+		return nil
+	}
+
+	p, err := m.Marshal()
+	if err != nil {
+		return err
+	}
+	writes = append(writes, write{manifest.Filename, p})
+	stg, err := stage.New(dir)
+	if err != nil {
+		return err
+	}
+	stg.Conflict = func(rel string) (stage.Policy, error) { return stage.Overwrite, nil }
+	for _, w := range writes {
+		if err := stg.WriteFile(filepath.FromSlash(w.rel), w.content, 0644); err != nil {
+			stg.Rollback()
+			return err
+		}
+	}
+	if err := stg.Commit(); err != nil {
+		return err
+	}
+	if n := counts[upgradeConflict]; n > 0 {
+		return fmt.Errorf("%d files have conflicts", n)
+	}
+	return nil
+}
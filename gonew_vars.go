@@ -0,0 +1,76 @@
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"strings"
+
+	"github.com/bmatsuo/gonew/config"
+)
+
+// Parameter values given with -var flags.
+type varFlags map[string]string
+
+func (vars varFlags) String() string {
+	pairs := make([]string, 0, len(vars))
+	for k, v := range vars {
+		pairs = append(pairs, k+"="+v)
+	}
+	sort.Strings(pairs)
+	return strings.Join(pairs, " ")
+}
+
+func (vars varFlags) Set(s string) error {
+	pair := strings.SplitN(s, "=", 2)
+	if len(pair) != 2 || pair[0] == "" {
+		return fmt.Errorf("expected key=value: %q", s)
+	}
+	vars[pair[0]] = pair[1]
+	return nil
+}
+
+// Reads user input from the terminal.
+var stdin = bufio.NewReader(os.Stdin)
+
+// True if stdin is a terminal the user can be prompted on.
+func interactive() bool {
+	info, err := os.Stdin.Stat()
+	return err == nil && info.Mode()&os.ModeCharDevice != 0
+}
+
+// Prompt for a parameter value until an acceptable one is given.
+func promptVar(name string, param *config.ParameterConfig) (interface{}, error) {
+	prompt := name
+	if param.Description != "" {
+		prompt += " (" + param.Description + ")"
+	}
+	if param.Type == config.ParameterChoice {
+		prompt += " [" + strings.Join(param.Choices, "|") + "]"
+	} else if param.Type != "" && param.Type != config.ParameterString {
+		prompt += " [" + param.Type + "]"
+	}
+	if param.Default != "" {
+		prompt += " (default " + param.Default + ")"
+	}
+	prompt += ": "
+	for {
+		line, err := readLine(stdin, prompt)
+		if err != nil && err != io.EOF {
+			return nil, fmt.Errorf("parameter %s: %v", name, err)
+		}
+		if line == "" {
+			line = param.Default
+		}
+		v, perr := param.Parse(line)
+		if perr == nil {
+			return v, nil
+		}
+		if err == io.EOF {
+			return nil, fmt.Errorf("parameter %s: %v", name, perr)
+		}
+		fmt.Fprintf(os.Stderr, "invalid value: %v\n", perr)
+	}
+}
@@ -0,0 +1,232 @@
+/*
+Package gonewtest tests project types against golden directories. A golden
+directory holds the files a project type is expected to generate in the
+output directory, as rendered at a fixed time. Template repositories check
+their golden directories in and test them with go test or "gonew test".
+
+	var update = flag.Bool("update", false, "update golden directories")
+
+	func TestPkg(t *testing.T) {
+		g := generator.New(conf, generator.Options{Strict: true})
+		gonewtest.Test(t, g, &gonewtest.Case{Project: "pkg", Golden: "testdata/pkg"}, *update)
+	}
+*/
+package gonewtest
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/bmatsuo/gonew/diff"
+	"github.com/bmatsuo/gonew/generator"
+)
+
+// The time projects are rendered at when a Case has none.
+var DefaultTime = time.Date(2006, time.January, 2, 15, 4, 5, 0, time.UTC)
+
+// The default target name of a Case.
+const DefaultName = "example"
+
+// A project type rendered for a golden test.
+type Case struct {
+	Project     string
+	Environment string            // The environment (default: the config's default)
+	Name        string            // The target name (default: DefaultName)
+	Package     string            // The package name (default: Name)
+	Module      string            // The module path
+	Vars        map[string]string // Parameter values
+	Time        time.Time         // When the project is rendered (default: DefaultTime)
+	Golden      string            // The golden directory
+}
+
+// How a rendered project differs from its golden directory. Paths are
+// relative to the golden directory and slash-separated.
+type Result struct {
+	Golden     string
+	Differ     []string // Files whose golden content differs
+	Missing    []string // Rendered files the golden directory lacks
+	Unexpected []string // Golden files that were not rendered
+	Diff       string   // Unified diffs from the golden files to the rendered ones
+	Warnings   []string // Warnings rendering the project
+}
+
+// The golden directory matches the rendered project.
+func (r *Result) OK() bool { return len(r.Differ)+len(r.Missing)+len(r.Unexpected) == 0 }
+
+// Describe the differences, like "gonew diff".
+func (r *Result) String() string {
+	var b strings.Builder
+	b.WriteString(r.Diff)
+	for _, path := range r.Missing {
+		fmt.Fprintf(&b, "missing %s\n", filepath.Join(r.Golden, filepath.FromSlash(path)))
+	}
+	for _, path := range r.Unexpected {
+		fmt.Fprintf(&b, "unexpected %s\n", filepath.Join(r.Golden, filepath.FromSlash(path)))
+	}
+	return b.String()
+}
+
+// Summarize the differences.
+func (r *Result) Err() error {
+	if r.OK() {
+		return nil
+	}
+	return fmt.Errorf("%s: %d files differ, %d missing, %d unexpected", r.Golden, len(r.Differ), len(r.Missing), len(r.Unexpected))
+}
+
+// Render the project of c with the configuration and templates of g. Nothing
+// is written and no manifest is rendered. File paths are relative to the
+// output directory and slash-separated.
+func Render(ctx context.Context, g *generator.Generator, c *Case) ([]*generator.File, []string, error) {
+	name, t := c.Name, c.Time
+	if name == "" {
+		name = DefaultName
+	}
+	if t.IsZero() {
+		t = DefaultTime
+	}
+	gen := *g
+	gen.Options = generator.Options{
+		Project:     c.Project,
+		Environment: c.Environment,
+		Name:        name,
+		Package:     c.Package,
+		Module:      c.Module,
+		Vars:        c.Vars,
+		Strict:      g.Strict,
+		Time:        t,
+	}
+	r, err := gen.Render(ctx)
+	if err != nil {
+		return nil, nil, fmt.Errorf("%s: %v", c.Project, err)
+	}
+	files := make([]*generator.File, len(r.Files))
+	for i, file := range r.Files {
+		if filepath.IsAbs(file.Path) || strings.HasPrefix(file.Path, "..") {
+			return nil, nil, fmt.Errorf("%s: file outside of the output directory: %s", c.Project, file.Path)
+		}
+		rfile := *file
+		rfile.Path = filepath.ToSlash(file.Path)
+		files[i] = &rfile
+	}
+	return files, r.Warnings, nil
+}
+
+// Render the project of c and compare it with the golden directory.
+func Compare(ctx context.Context, g *generator.Generator, c *Case) (*Result, error) {
+	files, warnings, err := Render(ctx, g, c)
+	if err != nil {
+		return nil, err
+	}
+	return compare(c.Golden, files, warnings)
+}
+
+// Render the project of c and replace the golden directory with it. The
+// result describes the golden directory before it was replaced.
+func Update(ctx context.Context, g *generator.Generator, c *Case) (*Result, error) {
+	files, warnings, err := Render(ctx, g, c)
+	if err != nil {
+		return nil, err
+	}
+	r, err := compare(c.Golden, files, warnings)
+	if err != nil {
+		return nil, err
+	}
+	for _, path := range r.Unexpected {
+		if err := os.Remove(filepath.Join(c.Golden, filepath.FromSlash(path))); err != nil {
+			return nil, err
+		}
+	}
+	for _, file := range files {
+		path := filepath.Join(c.Golden, filepath.FromSlash(file.Path))
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			return nil, err
+		}
+		if err := os.WriteFile(path, file.Content, 0644); err != nil {
+			return nil, err
+		}
+	}
+	return r, nil
+}
+
+// The subset of testing.TB used by Test.
+type TB interface {
+	Helper()
+	Error(args ...interface{})
+	Fatal(args ...interface{})
+	Logf(format string, args ...interface{})
+}
+
+// Compare the project of c with its golden directory, failing t if they
+// differ. With update the golden directory is replaced instead.
+func Test(t TB, g *generator.Generator, c *Case, update bool) {
+	t.Helper()
+	compare := Compare
+	if update {
+		compare = Update
+	}
+	r, err := compare(context.Background(), g, c)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, warning := range r.Warnings {
+		t.Logf("warning: %s", warning)
+	}
+	if update && !r.OK() {
+		t.Logf("updated %s", c.Golden)
+	} else if !r.OK() {
+		t.Error(r.Err(), "\n", r)
+	}
+}
+
+func compare(golden string, files []*generator.File, warnings []string) (*Result, error) {
+	r := &Result{Golden: golden, Warnings: warnings}
+	expected := make(map[string]bool, len(files))
+	var diffs strings.Builder
+	for _, file := range files {
+		expected[file.Path] = true
+		path := filepath.Join(golden, filepath.FromSlash(file.Path))
+		p, err := os.ReadFile(path)
+		if os.IsNotExist(err) {
+			r.Missing = append(r.Missing, file.Path)
+			continue
+		} else if err != nil {
+			return nil, err
+		}
+		if bytes.Equal(p, file.Content) {
+			continue
+		}
+		r.Differ = append(r.Differ, file.Path)
+		if bytes.IndexByte(p, 0) >= 0 || bytes.IndexByte(file.Content, 0) >= 0 {
+			fmt.Fprintf(&diffs, "Binary file %s differs\n", path)
+			continue
+		}
+		diffs.WriteString(diff.Unified(path, path+" (rendered)", string(p), string(file.Content)))
+	}
+	r.Diff = diffs.String()
+	err := filepath.WalkDir(golden, func(path string, d fs.DirEntry, err error) error {
+		if os.IsNotExist(err) && path == golden {
+			return filepath.SkipDir
+		}
+		if err != nil || d.IsDir() {
+			return err
+		}
+		rel, err := filepath.Rel(golden, path)
+		if err != nil {
+			return err
+		}
+		if rel = filepath.ToSlash(rel); !expected[rel] {
+			r.Unexpected = append(r.Unexpected, rel)
+		}
+		return nil
+	})
+	sort.Strings(r.Unexpected)
+	return r, err
+}
@@ -0,0 +1,71 @@
+package gonewtest
+
+import (
+	"context"
+	"flag"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	"github.com/bmatsuo/gonew/config"
+	"github.com/bmatsuo/gonew/generator"
+)
+
+var update = flag.Bool("update", false, "update golden directories")
+
+func exampleGenerator(t *testing.T) *generator.Generator {
+	conf := new(config.Gonew)
+	if err := conf.UnmarshalFileJSON(filepath.Join("..", "gonew.json.example")); err != nil {
+		t.Fatal(err)
+	}
+	return generator.New(conf, generator.Options{Strict: true})
+}
+
+// The standard templates, as configured by the example config.
+func TestStandard(t *testing.T) {
+	g := exampleGenerator(t)
+	for _, name := range []string{"cmd", "lib", "pkgtest", "newbsd"} {
+		Test(t, g, &Case{Project: name, Golden: filepath.Join("testdata", name)}, *update)
+	}
+}
+
+func TestCompare(t *testing.T) {
+	g := exampleGenerator(t)
+	c := &Case{Project: "pkg", Name: "mp3", Golden: filepath.Join(t.TempDir(), "pkg")}
+	r, err := Compare(context.Background(), g, c)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if r.OK() || len(r.Missing) == 0 || r.Err() == nil {
+		t.Fatalf("unexpected result: %+v", r)
+	}
+	missing := r.Missing
+
+	if err := os.MkdirAll(c.Golden, 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(c.Golden, "old.txt"), nil, 0644); err != nil {
+		t.Fatal(err)
+	}
+	if r, err = Update(context.Background(), g, c); err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(r.Missing, missing) || !reflect.DeepEqual(r.Unexpected, []string{"old.txt"}) {
+		t.Errorf("unexpected update: %+v", r)
+	}
+	if r, err = Compare(context.Background(), g, c); err != nil || !r.OK() {
+		t.Fatalf("updated golden directory differs: %v %v", err, r)
+	}
+
+	if err := os.WriteFile(filepath.Join(c.Golden, "mp3", "mp3.go"), []byte("package mp3\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	c.Time = DefaultTime.AddDate(1, 0, 0)
+	if r, err = Compare(context.Background(), g, c); err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(r.Differ, []string{"mp3/LICENSE", "mp3/README.md", "mp3/mp3.go"}) || r.Diff == "" {
+		t.Errorf("unexpected differences: %v\n%s", r.Differ, r.Diff)
+	}
+}
@@ -0,0 +1,13 @@
+// Copyright 2006, Bryan Matsuo. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+// example.go [created: Mon,  2 Jan 2006]
+
+package main
+
+import "fmt"
+
+func main() {
+	fmt.Println("Hello, Bryan Matsuo!")
+}
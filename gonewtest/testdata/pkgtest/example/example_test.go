@@ -0,0 +1,13 @@
+// Copyright 2006, Bryan Matsuo. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+// example_test.go [created: Mon,  2 Jan 2006]
+
+package example
+
+import "testing"
+
+func TestTestingTesting(t *testing.T) {
+	t.Logf("A stub")
+}
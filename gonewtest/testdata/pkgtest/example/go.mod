@@ -0,0 +1,3 @@
+module github.com/bmatsuo/example
+
+go 1.21
@@ -0,0 +1,102 @@
+/*
+Package gosrc formats generated Go source files.
+
+Format does what gofmt does. Imports also rewrites the imports of a file into a
+single import declaration, grouped like an ImportSet.
+*/
+package gosrc
+
+import (
+	"bytes"
+	"fmt"
+	"go/ast"
+	"go/format"
+	"go/parser"
+	"go/scanner"
+	"go/token"
+	"strconv"
+)
+
+// A syntax error in Go source. Line and Column count from 1.
+type SyntaxError struct {
+	Line, Column int
+	Msg          string
+}
+
+func (err *SyntaxError) Error() string {
+	return fmt.Sprintf("%d:%d: %s", err.Line, err.Column, err.Msg)
+}
+
+// Convert the first of the errors returned by the go/parser package to a
+// *SyntaxError.
+func syntaxError(err error) error {
+	if list, ok := err.(scanner.ErrorList); ok && len(list) > 0 {
+		err = list[0]
+	}
+	if e, ok := err.(*scanner.Error); ok {
+		return &SyntaxError{e.Pos.Line, e.Pos.Column, e.Msg}
+	}
+	return err
+}
+
+// Format src like gofmt. A syntax error is returned as a *SyntaxError.
+func Format(src []byte) ([]byte, error) {
+	out, err := format.Source(src)
+	if err != nil {
+		return nil, syntaxError(err)
+	}
+	return out, nil
+}
+
+// Format src like gofmt after merging, grouping and sorting its imports (see
+// ImportSet). Packages in the module local form the last group. The imports
+// are left in place when comments are among them or when the file uses cgo,
+// whose import must stay separate. A syntax error is returned as a
+// *SyntaxError.
+func Imports(src []byte, local string) ([]byte, error) {
+	fset := token.NewFileSet()
+	f, err := parser.ParseFile(fset, "", src, parser.ParseComments)
+	if err != nil {
+		return nil, syntaxError(err)
+	}
+	var decls []*ast.GenDecl
+	for _, decl := range f.Decls {
+		if gen, ok := decl.(*ast.GenDecl); ok && gen.Tok == token.IMPORT {
+			decls = append(decls, gen)
+		}
+	}
+	if len(decls) == 0 {
+		return Format(src)
+	}
+	start, end := decls[0].Pos(), decls[len(decls)-1].End()
+	for _, group := range f.Comments {
+		if group.End() > start && group.Pos() < end {
+			return Format(src)
+		}
+	}
+
+	imports := &ImportSet{Local: local}
+	for _, decl := range decls {
+		for _, spec := range decl.Specs {
+			spec := spec.(*ast.ImportSpec)
+			path, err := strconv.Unquote(spec.Path.Value)
+			if err != nil {
+				return nil, err
+			}
+			if path == "C" {
+				return Format(src)
+			}
+			imp := importSpec{path: path}
+			if spec.Name != nil {
+				imp.name = spec.Name.Name
+			}
+			imports.add(imp)
+		}
+	}
+
+	buf := new(bytes.Buffer)
+	buf.Write(src[:fset.Position(start).Offset])
+	buf.WriteString(imports.Decl())
+	buf.Write(src[fset.Position(end).Offset:])
+	return Format(buf.Bytes())
+}
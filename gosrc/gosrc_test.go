@@ -0,0 +1,63 @@
+package gosrc
+
+import (
+	"testing"
+)
+
+func TestFormat(t *testing.T) {
+	out, err := Format([]byte("package main\nfunc main() {\n    println(1)\n}\n"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(out) != "package main\n\nfunc main() {\n\tprintln(1)\n}\n" {
+		t.Errorf("unexpected output:\n%s", out)
+	}
+
+	_, err = Format([]byte("package main\n\nfunc main() {\n\tprintln(1\n}\n"))
+	serr, ok := err.(*SyntaxError)
+	if !ok {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if serr.Line != 4 {
+		t.Errorf("unexpected error line: %v", serr)
+	}
+}
+
+func TestImports(t *testing.T) {
+	for i, test := range []struct{ src, out string }{
+		{
+			"package a\n\n\n\nimport \"os\"\nimport \"fmt\"\n\nvar _ = fmt.Sprint\nvar _ = os.Exit\n",
+			"package a\n\nimport (\n\t\"fmt\"\n\t\"os\"\n)\n\nvar _ = fmt.Sprint\nvar _ = os.Exit\n",
+		},
+		{
+			"package a\nimport (\n\t\"github.com/x/y\"\n\tstr \"strings\"\n\t\"fmt\"\n\t\"fmt\"\n)\n",
+			"package a\n\nimport (\n\t\"fmt\"\n\tstr \"strings\"\n\n\t\"github.com/x/y\"\n)\n",
+		},
+		{
+			"package a\nimport \"example.com/a/b\"\nimport \"example.com/x\"\nimport \"os\"\n",
+			"package a\n\nimport (\n\t\"os\"\n\n\t\"example.com/x\"\n\n\t\"example.com/a/b\"\n)\n",
+		},
+		{
+			"package a\nimport (\"testing\")\n",
+			"package a\n\nimport \"testing\"\n",
+		},
+		{
+			// imports with comments are only sorted, by gofmt.
+			"package a\n\nimport (\n\t\"os\" // exit\n\t\"fmt\"\n)\nimport \"io\"\n",
+			"package a\n\nimport (\n\t\"fmt\"\n\t\"os\" // exit\n)\nimport \"io\"\n",
+		},
+		{
+			"package a\n",
+			"package a\n",
+		},
+	} {
+		out, err := Imports([]byte(test.src), "example.com/a")
+		if err != nil {
+			t.Errorf("test %d: %v", i, err)
+			continue
+		}
+		if string(out) != test.out {
+			t.Errorf("test %d: unexpected output:\n%s", i, out)
+		}
+	}
+}
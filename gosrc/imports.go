@@ -0,0 +1,177 @@
+package gosrc
+
+import (
+	"bytes"
+	"fmt"
+	"go/token"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// Stands in for the import declaration of a file until every template of the
+// file has added its imports (see ImportSet.Func).
+const Placeholder = "\x00gonew:imports\x00"
+
+type importSpec struct {
+	name string // an alias, "_" or "."; empty for the package name
+	path string
+}
+
+// Parse an import written as in a Go import declaration, without the
+// "import" keyword, or with an unquoted path. An alias, "_" (a blank import)
+// or "." (a dot import) may precede the path.
+//
+//	fmt
+//	"net/http"
+//	str strings
+//	_ "embed"
+func ParseImport(spec string) (name, path string, err error) {
+	fields := strings.Fields(spec)
+	switch len(fields) {
+	case 1:
+		path = fields[0]
+	case 2:
+		name, path = fields[0], fields[1]
+	default:
+		return "", "", fmt.Errorf("invalid import: %q", spec)
+	}
+	if strings.HasPrefix(path, `"`) || strings.HasPrefix(path, "`") {
+		if path, err = strconv.Unquote(path); err != nil {
+			return "", "", fmt.Errorf("invalid import path: %s", fields[len(fields)-1])
+		}
+	}
+	if path == "" || strings.ContainsAny(path, "\" \t\\") {
+		return "", "", fmt.Errorf("invalid import path: %q", path)
+	}
+	if name != "" && name != "_" && name != "." && !token.IsIdentifier(name) {
+		return "", "", fmt.Errorf("invalid import name: %q", name)
+	}
+	return name, path, nil
+}
+
+// Whether path names a package of the standard library, which has no dot in
+// its first element.
+func IsStandard(path string) bool {
+	elem := path
+	if i := strings.Index(path, "/"); i >= 0 {
+		elem = path[:i]
+	}
+	return !strings.Contains(elem, ".")
+}
+
+// The imports of a Go file, written as one declaration. Imports are
+// deduplicated and split into groups, each sorted by path: the standard
+// library, then other packages, then the other packages of the module Local.
+type ImportSet struct {
+	Local  string // a module path (optional)
+	specs  []importSpec
+	seen   map[importSpec]bool
+	placed bool // Func has returned the Placeholder
+}
+
+// Add imports, written as for ParseImport.
+func (s *ImportSet) Add(specs ...string) error {
+	for _, spec := range specs {
+		name, path, err := ParseImport(spec)
+		if err != nil {
+			return err
+		}
+		s.add(importSpec{name, path})
+	}
+	return nil
+}
+
+func (s *ImportSet) add(imp importSpec) {
+	if s.seen == nil {
+		s.seen = make(map[importSpec]bool)
+	}
+	if !s.seen[imp] {
+		s.seen[imp] = true
+		s.specs = append(s.specs, imp)
+	}
+}
+
+// A template function adding imports. Its first call returns the
+// Placeholder, where Expand will put the import declaration. Later calls
+// return nothing, so imports may be added from anywhere in a file.
+func (s *ImportSet) Func(specs ...string) (string, error) {
+	if err := s.Add(specs...); err != nil {
+		return "", err
+	}
+	if s.placed {
+		return "", nil
+	}
+	s.placed = true
+	return Placeholder, nil
+}
+
+// Replace the Placeholder in content with the import declaration and reset
+// s for another file.
+func (s *ImportSet) Expand(content []byte) []byte {
+	if s.placed {
+		content = bytes.Replace(content, []byte(Placeholder), []byte(s.Decl()), 1)
+	}
+	*s = ImportSet{Local: s.Local}
+	return content
+}
+
+func (s *ImportSet) isLocal(path string) bool {
+	return s.Local != "" && (path == s.Local || strings.HasPrefix(path, s.Local+"/"))
+}
+
+// The import declaration, which is empty if there are no imports. A single
+// import is declared without parentheses.
+func (s *ImportSet) Decl() string {
+	if len(s.specs) == 0 {
+		return ""
+	}
+	var std, other, local []importSpec
+	for _, imp := range s.specs {
+		switch {
+		case IsStandard(imp.path):
+			std = append(std, imp) // even in a module without a dot, like "log"
+		case s.isLocal(imp.path):
+			local = append(local, imp)
+		default:
+			other = append(other, imp)
+		}
+	}
+	buf := new(strings.Builder)
+	if len(s.specs) == 1 {
+		buf.WriteString("import ")
+		writeImport(buf, s.specs[0])
+		return buf.String()
+	}
+	buf.WriteString("import (\n")
+	sep := false
+	for _, group := range [][]importSpec{std, other, local} {
+		if len(group) == 0 {
+			continue
+		}
+		sort.Slice(group, func(i, j int) bool {
+			if group[i].path != group[j].path {
+				return group[i].path < group[j].path
+			}
+			return group[i].name < group[j].name
+		})
+		if sep {
+			buf.WriteString("\n")
+		}
+		sep = true
+		for _, imp := range group {
+			buf.WriteString("\t")
+			writeImport(buf, imp)
+			buf.WriteString("\n")
+		}
+	}
+	buf.WriteString(")")
+	return buf.String()
+}
+
+func writeImport(buf *strings.Builder, imp importSpec) {
+	if imp.name != "" {
+		buf.WriteString(imp.name + " ")
+	}
+	buf.WriteString(strconv.Quote(imp.path))
+}
@@ -0,0 +1,85 @@
+package gosrc
+
+import (
+	"testing"
+)
+
+func TestParseImport(t *testing.T) {
+	for _, test := range []struct{ spec, name, path string }{
+		{"fmt", "", "fmt"},
+		{`"net/http"`, "", "net/http"},
+		{"str strings", "str", "strings"},
+		{`str "strings"`, "str", "strings"},
+		{`_ "embed"`, "_", "embed"},
+		{". fmt", ".", "fmt"},
+	} {
+		name, path, err := ParseImport(test.spec)
+		if err != nil {
+			t.Errorf("%q: %v", test.spec, err)
+		} else if name != test.name || path != test.path {
+			t.Errorf("%q: parsed as %q %q", test.spec, name, path)
+		}
+	}
+	for _, spec := range []string{"", `"fmt`, "a b c", "1x fmt", `"a b"`} {
+		if _, _, err := ParseImport(spec); err == nil {
+			t.Errorf("%q: no error", spec)
+		}
+	}
+}
+
+func TestIsStandard(t *testing.T) {
+	for path, std := range map[string]bool{
+		"fmt":                        true,
+		"net/http":                   true,
+		"github.com/bmatsuo/gonew":   false,
+		"golang.org/x/tools/imports": false,
+	} {
+		if IsStandard(path) != std {
+			t.Errorf("IsStandard(%q) != %v", path, std)
+		}
+	}
+}
+
+func TestImportSet(t *testing.T) {
+	s := &ImportSet{Local: "example.com/mod"}
+	var out string
+	for _, specs := range [][]string{
+		{"os", "fmt"},
+		{"example.com/mod/util", "github.com/x/y"},
+		{"fmt", `_ "embed"`, "str strings"},
+	} {
+		p, err := s.Func(specs...)
+		if err != nil {
+			t.Fatal(err)
+		}
+		out += p + "|"
+	}
+	expect := "import (\n" +
+		"\t_ \"embed\"\n\t\"fmt\"\n\t\"os\"\n\tstr \"strings\"\n\n" +
+		"\t\"github.com/x/y\"\n\n" +
+		"\t\"example.com/mod/util\"\n" +
+		")|||"
+	if got := string(s.Expand([]byte(out))); got != expect {
+		t.Errorf("unexpected expansion:\n%s", got)
+	}
+
+	// Expand resets the set for the next file.
+	if got := string(s.Expand([]byte("x"))); got != "x" {
+		t.Errorf("unexpected expansion: %q", got)
+	}
+	p, _ := s.Func("fmt")
+	if got := string(s.Expand([]byte(p))); got != `import "fmt"` {
+		t.Errorf("unexpected expansion: %q", got)
+	}
+}
+
+func TestImportSetStandardModule(t *testing.T) {
+	s := &ImportSet{Local: "log"}
+	if err := s.Add("log", "fmt", "github.com/x/y"); err != nil {
+		t.Fatal(err)
+	}
+	expect := "import (\n\t\"fmt\"\n\t\"log\"\n\n\t\"github.com/x/y\"\n)"
+	if got := s.Decl(); got != expect {
+		t.Errorf("unexpected declaration:\n%s", got)
+	}
+}
@@ -0,0 +1,119 @@
+/*
+Package manifest records how a project was generated.
+
+A manifest is stored in the root of a generated project as a JSON file named
+".gonew.json". It lists each Generation, a run of gonew that produced files in
+the project. Usually there is one. More are added when other project types are
+generated into an existing project.
+*/
+package manifest
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+// The name of the manifest file in a project's root directory.
+const Filename = ".gonew.json"
+
+type Manifest struct {
+	Generations []*Generation
+}
+
+// One run of gonew.
+type Generation struct {
+	Version     string            // The version of gonew
+	Project     string            // The project type
+	Environment string            // The environment name
+	Name        string            // The target name
+	Package     string            // The package name
+	Module      string            // The module path
+	Vars        map[string]string // Parameter values
+	Templates   []string          // Template sources, highest precedence first
+	Time        time.Time         // The time of generation
+	Files       []*File           // The files generated (sorted by Path)
+}
+
+// A generated file.
+type File struct {
+	Path      string   // Slash-separated, relative to the project root
+	Templates []string // The templates rendered to produce the file
+	Hash      string   // The hash of the generated content (see Hash)
+	Content   string   // The generated content, the base for upgrades
+}
+
+// The hash of file content recorded in a manifest.
+func Hash(content []byte) string {
+	sum := sha256.Sum256(content)
+	return "sha256:" + hex.EncodeToString(sum[:])
+}
+
+// Read the manifest in the project directory dir.
+func Read(dir string) (*Manifest, error) {
+	p, err := os.ReadFile(filepath.Join(dir, Filename))
+	if err != nil {
+		return nil, err
+	}
+	m := new(Manifest)
+	if err := json.Unmarshal(p, m); err != nil {
+		return nil, err
+	}
+	return m, nil
+}
+
+// Like Read, but a missing manifest is returned as an empty one.
+func ReadOrEmpty(dir string) (*Manifest, error) {
+	m, err := Read(dir)
+	if os.IsNotExist(err) {
+		return new(Manifest), nil
+	}
+	return m, err
+}
+
+// The manifest as indented JSON.
+func (m *Manifest) Marshal() ([]byte, error) {
+	p, err := json.MarshalIndent(m, "", "\t")
+	if err != nil {
+		return nil, err
+	}
+	return append(p, '\n'), nil
+}
+
+// Add a generation. Files of gen are removed from earlier generations, which
+// are dropped when they have no files left.
+func (m *Manifest) Add(gen *Generation) {
+	owned := make(map[string]bool, len(gen.Files))
+	for _, file := range gen.Files {
+		owned[file.Path] = true
+	}
+	gens := m.Generations[:0]
+	for _, g := range m.Generations {
+		files := g.Files[:0]
+		for _, file := range g.Files {
+			if !owned[file.Path] {
+				files = append(files, file)
+			}
+		}
+		g.Files = files
+		if len(files) > 0 {
+			gens = append(gens, g)
+		}
+	}
+	m.Generations = append(gens, gen)
+}
+
+// The generation that produced the file at path, and its record, if any.
+func (m *Manifest) File(path string) (*Generation, *File) {
+	for _, g := range m.Generations {
+		for _, file := range g.Files {
+			if file.Path == path {
+				return g, file
+			}
+		}
+	}
+	return nil, nil
+}
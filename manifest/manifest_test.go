@@ -0,0 +1,59 @@
+package manifest
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestManifest(t *testing.T) {
+	m := new(Manifest)
+	m.Add(&Generation{Project: "pkg", Files: []*File{
+		{Path: "LICENSE", Hash: Hash([]byte("bsd"))},
+		{Path: "foo.go", Hash: Hash([]byte("package foo"))},
+	}})
+	m.Add(&Generation{Project: "travis", Files: []*File{
+		{Path: ".travis.yml", Hash: Hash([]byte("language: go"))},
+	}})
+	m.Add(&Generation{Project: "mit", Files: []*File{
+		{Path: "LICENSE", Hash: Hash([]byte("mit"))},
+	}})
+	if len(m.Generations) != 3 {
+		t.Fatalf("unexpected generations: %d", len(m.Generations))
+	}
+	if g, f := m.File("LICENSE"); g == nil || g.Project != "mit" || f.Hash != Hash([]byte("mit")) {
+		t.Errorf("LICENSE not owned by the latest generation")
+	}
+	m.Add(&Generation{Project: "travis", Files: []*File{
+		{Path: ".travis.yml", Hash: Hash([]byte("language: go\n"))},
+	}})
+	var projects []string
+	for _, g := range m.Generations {
+		projects = append(projects, g.Project)
+	}
+	if !reflect.DeepEqual(projects, []string{"pkg", "mit", "travis"}) {
+		t.Errorf("unexpected generations: %v", projects)
+	}
+
+	dir := t.TempDir()
+	m.Generations[0].Time = time.Date(2026, 10, 18, 12, 0, 0, 0, time.UTC)
+	p, err := m.Marshal()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(dir, Filename), p, 0644); err != nil {
+		t.Fatal(err)
+	}
+	m2, err := Read(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(m, m2) {
+		t.Errorf("manifest changed by writing and reading")
+	}
+	if m, err := ReadOrEmpty(t.TempDir()); err != nil || len(m.Generations) != 0 {
+		t.Errorf("unexpected empty manifest: %v %v", m, err)
+	}
+}
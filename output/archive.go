@@ -0,0 +1,135 @@
+package output
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"compress/gzip"
+	"fmt"
+	"io"
+	"os"
+	"path"
+	"strings"
+	"time"
+)
+
+// The archive formats.
+const (
+	TarGz = "tar.gz"
+	Zip   = "zip"
+)
+
+// The archive format for a file name, by its extension, or "".
+func FormatOf(name string) string {
+	switch {
+	case strings.HasSuffix(name, ".tar.gz"), strings.HasSuffix(name, ".tgz"):
+		return TarGz
+	case strings.HasSuffix(name, ".zip"):
+		return Zip
+	}
+	return ""
+}
+
+// A Backend writing its files to an archive when committed. The archive has
+// an entry for each file and for each directory containing one.
+type Archive struct {
+	Memory
+	Format  string    // TarGz or Zip
+	ModTime time.Time // The modification time of every entry
+
+	// Opens the destination of the archive. It is only called by Commit,
+	// so nothing is written when the archive is rolled back.
+	Open func() (io.WriteCloser, error)
+}
+
+// Write the archive to the destination.
+func (a *Archive) Commit() (err error) {
+	if a.Format != TarGz && a.Format != Zip {
+		return fmt.Errorf("unknown archive format: %q", a.Format)
+	}
+	w, err := a.Open()
+	if err != nil {
+		return err
+	}
+	defer func() {
+		if cerr := w.Close(); err == nil {
+			err = cerr
+		}
+	}()
+	if a.Format == Zip {
+		return a.writeZip(w)
+	}
+	return a.writeTarGz(w)
+}
+
+// The directories containing files, parents first, each with a trailing
+// slash.
+func dirs(files []*File) []string {
+	var names []string
+	seen := make(map[string]bool)
+	for _, file := range files {
+		var parents []string
+		for dir := path.Dir(file.Name); dir != "." && !seen[dir]; dir = path.Dir(dir) {
+			seen[dir] = true
+			parents = append(parents, dir+"/")
+		}
+		for i := len(parents) - 1; i >= 0; i-- {
+			names = append(names, parents[i])
+		}
+	}
+	return names
+}
+
+func (a *Archive) writeTarGz(w io.Writer) error {
+	gz := gzip.NewWriter(w)
+	tw := tar.NewWriter(gz)
+	files := a.Files()
+	for _, dir := range dirs(files) {
+		hdr := &tar.Header{Typeflag: tar.TypeDir, Name: dir, Mode: 0755, ModTime: a.ModTime}
+		if err := tw.WriteHeader(hdr); err != nil {
+			return err
+		}
+	}
+	for _, file := range files {
+		hdr := &tar.Header{
+			Typeflag: tar.TypeReg,
+			Name:     file.Name,
+			Mode:     int64(file.Mode.Perm()),
+			Size:     int64(len(file.Content)),
+			ModTime:  a.ModTime,
+		}
+		if err := tw.WriteHeader(hdr); err != nil {
+			return err
+		}
+		if _, err := tw.Write(file.Content); err != nil {
+			return err
+		}
+	}
+	if err := tw.Close(); err != nil {
+		return err
+	}
+	return gz.Close()
+}
+
+func (a *Archive) writeZip(w io.Writer) error {
+	zw := zip.NewWriter(w)
+	files := a.Files()
+	for _, dir := range dirs(files) {
+		hdr := &zip.FileHeader{Name: dir, Modified: a.ModTime}
+		hdr.SetMode(os.ModeDir | 0755)
+		if _, err := zw.CreateHeader(hdr); err != nil {
+			return err
+		}
+	}
+	for _, file := range files {
+		hdr := &zip.FileHeader{Name: file.Name, Method: zip.Deflate, Modified: a.ModTime}
+		hdr.SetMode(file.Mode.Perm())
+		fw, err := zw.CreateHeader(hdr)
+		if err != nil {
+			return err
+		}
+		if _, err := fw.Write(file.Content); err != nil {
+			return err
+		}
+	}
+	return zw.Close()
+}
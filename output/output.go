@@ -0,0 +1,87 @@
+/*
+Package output defines where generated files go.
+
+A Backend receives every file of a project, then either commits them or rolls
+them back. A *stage.Stage is the file system backend: it writes a directory
+tree in place. Memory holds the files in memory, and Archive writes them to a
+tar.gz or zip archive when committed. Neither of those touches the file
+system.
+*/
+package output
+
+import (
+	"fmt"
+	"os"
+	"path"
+	"sort"
+	"strings"
+
+	"github.com/bmatsuo/gonew/stage"
+)
+
+// A destination for generated files. Names are slash-separated paths
+// relative to the destination's root.
+type Backend interface {
+	WriteFile(name string, content []byte, perm os.FileMode) error
+	Commit() error   // Output the files written
+	Rollback() error // Discard the files written
+}
+
+var _ Backend = (*stage.Stage)(nil)
+
+// A file held by Memory.
+type File struct {
+	Name    string
+	Content []byte
+	Mode    os.FileMode
+}
+
+// A Backend holding files in memory.
+type Memory struct {
+	files map[string]*File
+}
+
+// Check that name is a clean, relative, slash-separated path.
+func checkName(name string) error {
+	if name == "" || path.IsAbs(name) || path.Clean(name) != name ||
+		name == ".." || strings.HasPrefix(name, "../") || strings.Contains(name, "\\") {
+		return fmt.Errorf("invalid file name: %q", name)
+	}
+	return nil
+}
+
+// Hold a file, replacing any file with the same name.
+func (m *Memory) WriteFile(name string, content []byte, perm os.FileMode) error {
+	if err := checkName(name); err != nil {
+		return err
+	}
+	if m.files == nil {
+		m.files = make(map[string]*File)
+	}
+	m.files[name] = &File{name, append([]byte(nil), content...), perm}
+	return nil
+}
+
+// Commit does nothing; the files stay in memory.
+func (m *Memory) Commit() error { return nil }
+
+// Discard the files.
+func (m *Memory) Rollback() error {
+	m.files = nil
+	return nil
+}
+
+// The files held, sorted by name.
+func (m *Memory) Files() []*File {
+	files := make([]*File, 0, len(m.files))
+	for _, file := range m.files {
+		files = append(files, file)
+	}
+	sort.Slice(files, func(i, j int) bool { return files[i].Name < files[j].Name })
+	return files
+}
+
+// The file named name, or nil.
+func (m *Memory) File(name string) *File {
+	return m.files[name]
+}
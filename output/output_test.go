@@ -0,0 +1,145 @@
+package output
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"bytes"
+	"compress/gzip"
+	"io"
+	"reflect"
+	"testing"
+	"time"
+)
+
+type nopCloser struct{ io.Writer }
+
+func (nopCloser) Close() error { return nil }
+
+func writeFiles(t *testing.T, b Backend) {
+	for _, file := range []*File{
+		{"foo/foo.go", []byte("package foo\n"), 0644},
+		{"foo/.gitignore", []byte("*.o\n"), 0644},
+		{"foo/cmd/foo/main.go", []byte("package main\n"), 0644},
+	} {
+		if err := b.WriteFile(file.Name, file.Content, file.Mode); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+func TestMemory(t *testing.T) {
+	m := new(Memory)
+	writeFiles(t, m)
+	if err := m.WriteFile("foo/foo.go", []byte("package bar\n"), 0600); err != nil {
+		t.Fatal(err)
+	}
+	var names []string
+	for _, file := range m.Files() {
+		names = append(names, file.Name)
+	}
+	if !reflect.DeepEqual(names, []string{"foo/.gitignore", "foo/cmd/foo/main.go", "foo/foo.go"}) {
+		t.Errorf("unexpected files: %v", names)
+	}
+	if f := m.File("foo/foo.go"); f == nil || string(f.Content) != "package bar\n" || f.Mode != 0600 {
+		t.Errorf("file not replaced: %v", f)
+	}
+	for _, name := range []string{"", "/etc/passwd", "../x", "a/../../x", "./a", `a\b`} {
+		if err := m.WriteFile(name, nil, 0644); err == nil {
+			t.Errorf("invalid name accepted: %q", name)
+		}
+	}
+	m.Rollback()
+	if len(m.Files()) != 0 {
+		t.Errorf("files remain after rollback")
+	}
+}
+
+var archiveEntries = []string{
+	"foo/", "foo/cmd/", "foo/cmd/foo/",
+	"foo/.gitignore", "foo/cmd/foo/main.go", "foo/foo.go",
+}
+
+func TestTarGz(t *testing.T) {
+	buf := new(bytes.Buffer)
+	modTime := time.Date(2026, 10, 18, 0, 0, 0, 0, time.UTC)
+	a := &Archive{Format: TarGz, ModTime: modTime, Open: func() (io.WriteCloser, error) {
+		return nopCloser{buf}, nil
+	}}
+	writeFiles(t, a)
+	if err := a.Commit(); err != nil {
+		t.Fatal(err)
+	}
+	gz, err := gzip.NewReader(buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tr := tar.NewReader(gz)
+	var names []string
+	for {
+		hdr, err := tr.Next()
+		if err == io.EOF {
+			break
+		} else if err != nil {
+			t.Fatal(err)
+		}
+		names = append(names, hdr.Name)
+		if !hdr.ModTime.Equal(modTime) {
+			t.Errorf("%s: unexpected time %v", hdr.Name, hdr.ModTime)
+		}
+		if hdr.Name == "foo/foo.go" {
+			p, _ := io.ReadAll(tr)
+			if string(p) != "package foo\n" {
+				t.Errorf("unexpected content: %q", p)
+			}
+		}
+	}
+	if !reflect.DeepEqual(names, archiveEntries) {
+		t.Errorf("unexpected entries: %v", names)
+	}
+}
+
+func TestZip(t *testing.T) {
+	buf := new(bytes.Buffer)
+	a := &Archive{Format: Zip, ModTime: time.Now(), Open: func() (io.WriteCloser, error) {
+		return nopCloser{buf}, nil
+	}}
+	writeFiles(t, a)
+	if err := a.Commit(); err != nil {
+		t.Fatal(err)
+	}
+	zr, err := zip.NewReader(bytes.NewReader(buf.Bytes()), int64(buf.Len()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	var names []string
+	for _, f := range zr.File {
+		names = append(names, f.Name)
+	}
+	if !reflect.DeepEqual(names, archiveEntries) {
+		t.Errorf("unexpected entries: %v", names)
+	}
+}
+
+func TestArchiveRollback(t *testing.T) {
+	a := &Archive{Format: Zip, Open: func() (io.WriteCloser, error) {
+		t.Fatal("archive opened")
+		return nil, nil
+	}}
+	writeFiles(t, a)
+	if err := a.Rollback(); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestFormatOf(t *testing.T) {
+	for name, format := range map[string]string{
+		"foo.zip":    Zip,
+		"foo.tar.gz": TarGz,
+		"foo.tgz":    TarGz,
+		"foo.tar":    "",
+	} {
+		if FormatOf(name) != format {
+			t.Errorf("%s: format %q", name, FormatOf(name))
+		}
+	}
+}
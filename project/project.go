@@ -12,55 +12,163 @@ package project
 
 import (
 	"path"
+	"path/filepath"
 	"strings"
+	"time"
 
 	"github.com/bmatsuo/gonew/config"
 	"github.com/bmatsuo/gonew/extension"
 )
 
+// The base import path of projects whose environment has no BaseImportPath.
+//
+// Deprecated: Set the BaseImportPath of the project's environment, or give
+// the module path with WithModule.
 var BaseImportPath string
 
-func importPath(pkg string) string {
-	if BaseImportPath == "" {
-		return pkg
+// The data templates are rendered with. Strict templates see the struct, so
+// using a field it lacks fails. Other templates see its Map.
+type TemplateContext struct {
+	File    FileContext // Empty for templates of the project, like hooks
+	Root    string
+	Prefix  string
+	Package string
+	Project Interface
+	Env     *config.Environment
+	Vars    map[string]interface{}
+	X       map[string]interface{} // The registered extensions, with Time at the project's time
+}
+
+// The file a template is rendered for.
+type FileContext struct {
+	Name string
+	Path string // Relative to the output directory
+	Type string
+}
+
+// The template context of a file of p at file, a path relative to the output
+// directory. File is empty for templates of the project, like hooks.
+func Context(file, filetype string, p Interface) *TemplateContext {
+	var filename string
+	if file != "" {
+		filename = filepath.Base(file)
+	}
+	return &TemplateContext{
+		File:    FileContext{Name: filename, Path: file, Type: filetype},
+		Root:    p.Root(),
+		Prefix:  p.Prefix(),
+		Package: p.Package(),
+		Project: p,
+		Env:     p.Env(),
+		Vars:    p.Vars(),
+		X:       extensions(p.Time()),
 	}
-	return path.Join(BaseImportPath, pkg)
 }
 
-func Context(filename, filetype string, p Interface) interface{} {
+// The context as a map with the same keys, like File.Name. Templates using a
+// key it lacks render "<no value>" and can use index, as in (index . "Env").
+func (c *TemplateContext) Map() map[string]interface{} {
 	return map[string]interface{}{
 		"File": map[string]interface{}{
-			"Name": filename,
-			"Type": filetype,
+			"Name": c.File.Name,
+			"Path": c.File.Path,
+			"Type": c.File.Type,
 		},
-		"Prefix":  p.Prefix(),
-		"Package": p.Package(),
-		"Project": p,
-		"Env":     p.Env(),
-		"X":       extension.Extensions,
+		"Root":    c.Root,
+		"Prefix":  c.Prefix,
+		"Package": c.Package,
+		"Project": c.Project,
+		"Env":     c.Env,
+		"Vars":    c.Vars,
+		"X":       c.X,
 	}
 }
 
+// The registered extensions, with the Time extension at t.
+func extensions(t time.Time) map[string]interface{} {
+	x := make(map[string]interface{}, len(extension.Extensions))
+	for name, ext := range extension.Extensions {
+		x[name] = ext
+	}
+	x["Time"] = extension.Time(t)
+	return x
+}
+
 type Interface interface {
 	Name() string
+	Root() string
 	Prefix() string
 	Package() string
 	Import() string
+	Module() string
 	Env() *config.Environment
+	Vars() map[string]interface{}
+	Time() time.Time // When the project is generated
 }
 
-func New(name, pkg string, env *config.Environment) Interface {
-	return &project{name, pkg, env}
+// An optional project setting for New.
+type Option func(*project)
+
+// Set the project's module path. Without it the module path is derived from
+// the environment's BaseImportPath and the package name.
+func WithModule(path string) Option {
+	return func(p *project) { p.module = path }
+}
+
+// Set the directory the project is generated in. File paths and hook working
+// directories are relative to it. Without it they are relative to the current
+// directory.
+func WithRoot(dir string) Option {
+	return func(p *project) { p.root = dir }
+}
+
+// Set the time the project is generated. Without it the time is when the
+// project is created by New.
+func WithTime(t time.Time) Option {
+	return func(p *project) { p.time = t }
+}
+
+// Set the values of the project's parameters, available to templates as .Vars.
+func WithVars(vars map[string]interface{}) Option {
+	return func(p *project) { p.vars = vars }
+}
+
+func New(name, pkg string, env *config.Environment, opts ...Option) Interface {
+	p := &project{name: name, pkg: pkg, env: env, time: time.Now()}
+	for _, opt := range opts {
+		opt(p)
+	}
+	return p
 }
 
 type project struct {
-	name string
-	pkg  string
-	env  *config.Environment
+	name   string
+	pkg    string
+	module string
+	root   string
+	env    *config.Environment
+	vars   map[string]interface{}
+	time   time.Time
+}
+
+func (p *project) Name() string { return p.name }
+
+// The directory the project is generated in (see WithRoot).
+func (p *project) Root() string {
+	if p.root == "" {
+		return "."
+	}
+	return p.root
+}
+
+// The project directory, named for the project in the root directory.
+func (p *project) Prefix() string {
+	if p.root == "" {
+		return "./" + p.name
+	}
+	return filepath.Join(p.root, p.name)
 }
 
-func (p *project) Name() string   { return p.name }
-func (p *project) Prefix() string { return "./" + p.name } // XXX could be smarter
 func (p *project) Package() string {
 	if strings.HasPrefix(p.pkg, "go-") {
 		return p.pkg[3:]
@@ -70,5 +178,29 @@ func (p *project) Package() string {
 	}
 	return p.pkg
 }
-func (p *project) Import() string           { return importPath(p.pkg) }
+func (p *project) Import() string           { return p.Module() }
 func (p *project) Env() *config.Environment { return p.env }
+func (p *project) Time() time.Time          { return p.time }
+
+func (p *project) Vars() map[string]interface{} {
+	if p.vars == nil {
+		return map[string]interface{}{}
+	}
+	return p.vars
+}
+
+// The module path of the project. The project's root package has the module
+// path as its import path.
+func (p *project) Module() string {
+	if p.module != "" {
+		return p.module
+	}
+	base := BaseImportPath
+	if p.env != nil && p.env.BaseImportPath != "" {
+		base = p.env.BaseImportPath
+	}
+	if base == "" {
+		return p.pkg
+	}
+	return path.Join(base, p.pkg)
+}
@@ -7,11 +7,68 @@ package project
  */
 
 import (
-    "testing"
-)
+	"testing"
+	"time"
 
+	"github.com/bmatsuo/gonew/config"
+	"github.com/bmatsuo/gonew/extension"
+)
 
 func TestProject(t *testing.T) {
+	env := &config.Environment{BaseImportPath: "github.com/bmatsuo"}
+
+	p := New("go-mp3lib", "go-mp3lib", env)
+	if p.Package() != "mp3lib" {
+		t.Errorf("unexpected package: %q", p.Package())
+	}
+	if p.Module() != "github.com/bmatsuo/go-mp3lib" {
+		t.Errorf("unexpected module: %q", p.Module())
+	}
+	if p.Import() != p.Module() {
+		t.Errorf("import %q does not match module %q", p.Import(), p.Module())
+	}
+
+	other := New("mp3", "mp3", &config.Environment{BaseImportPath: "example.com"})
+	if other.Module() != "example.com/mp3" || p.Module() != "github.com/bmatsuo/go-mp3lib" {
+		t.Errorf("unexpected modules: %q, %q", other.Module(), p.Module())
+	}
 
+	BaseImportPath = "example.org"
+	other = New("mp3", "mp3", nil)
+	if other.Module() != "example.org/mp3" || p.Module() != "github.com/bmatsuo/go-mp3lib" {
+		t.Errorf("unexpected modules: %q, %q", other.Module(), p.Module())
+	}
+	BaseImportPath = ""
+
+	p = New("mp3", "mp3", env, WithModule("example.com/audio/mp3"))
+	if p.Module() != "example.com/audio/mp3" {
+		t.Errorf("unexpected module: %q", p.Module())
+	}
+	if p.Import() != "example.com/audio/mp3" {
+		t.Errorf("unexpected import: %q", p.Import())
+	}
+
+	if p.Root() != "." || p.Prefix() != "./mp3" {
+		t.Errorf("unexpected root %q and prefix %q", p.Root(), p.Prefix())
+	}
+
+	p = New("mp3", "mp3", env, WithRoot("/src/audio"))
+	if p.Root() != "/src/audio" || p.Prefix() != "/src/audio/mp3" {
+		t.Errorf("unexpected root %q and prefix %q", p.Root(), p.Prefix())
+	}
 }
 
+func TestContextTime(t *testing.T) {
+	when := time.Date(2006, time.January, 2, 15, 4, 5, 0, time.UTC)
+	p := New("mp3", "mp3", new(config.Environment), WithTime(when))
+	ctx := Context("mp3/mp3.go", "go", p)
+	if x, ok := ctx.X["Time"].(extension.Time); !ok || x.Now("2006") != "2006" {
+		t.Errorf("unexpected Time extension: %v", ctx.X["Time"])
+	}
+	if _, ok := ctx.X["Strings"]; !ok {
+		t.Errorf("missing Strings extension")
+	}
+	if ctx.File.Name != "mp3.go" || ctx.Package != "mp3" {
+		t.Errorf("unexpected context: %+v", ctx)
+	}
+}
@@ -0,0 +1,288 @@
+/*
+Package stage builds a file tree in a temporary directory and moves it into
+its destination only once it is complete.
+
+A Stage's temporary directory is created inside the destination so moving
+files into place is a rename on the same file system. By default Commit
+refuses to replace files that already exist; a Conflict function can choose
+another Policy for each of them. If Commit fails, or Rollback is called
+instead, everything the stage created is removed and existing files are
+restored.
+*/
+package stage
+
+import (
+	"bytes"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"github.com/bmatsuo/gonew/diff"
+)
+
+// An error returned by Commit when a staged path exists in the destination.
+type ErrExist string
+
+func (err ErrExist) Error() string { return "file exists: " + string(err) }
+
+// How Commit handles a staged file that exists in the destination.
+type Policy string
+
+const (
+	Fail      Policy = "fail"      // Abort the commit
+	Skip      Policy = "skip"      // Keep the existing file, discard the staged one
+	Overwrite Policy = "overwrite" // Replace the existing file
+	Backup    Policy = "backup"    // Rename the existing file (adding .bak) and replace it
+	Merge     Policy = "merge"     // Replace the existing file with conflict markers where they differ
+)
+
+// Parse the name of a Policy.
+func ParsePolicy(s string) (Policy, error) {
+	switch p := Policy(s); p {
+	case Fail, Skip, Overwrite, Backup, Merge:
+		return p, nil
+	}
+	return "", fmt.Errorf("unknown conflict policy: %q", s)
+}
+
+// A file tree staged for a destination directory.
+type Stage struct {
+	// Chooses the Policy for a staged file (given by its slash-separated
+	// path relative to the destination) that already exists. Commit fails
+	// on existing files when Conflict is nil.
+	Conflict func(rel string) (Policy, error)
+
+	// The files Commit merged with conflict markers (see Merge), as
+	// slash-separated paths relative to the destination.
+	Conflicts []string
+
+	root  string         // destination directory
+	dir   string         // temporary directory inside root
+	saved string         // temporary directory holding replaced files
+	undo  []func() error // reverts the changes made by Commit, in order
+	done  bool           // true once committed or rolled back
+}
+
+// Create a stage for the directory root, creating root if necessary.
+func New(root string) (*Stage, error) {
+	if err := os.MkdirAll(root, 0755); err != nil {
+		return nil, err
+	}
+	dir, err := os.MkdirTemp(root, ".gonew-stage-")
+	if err != nil {
+		return nil, err
+	}
+	return &Stage{root: root, dir: dir}, nil
+}
+
+// The temporary directory holding the staged tree.
+func (s *Stage) Dir() string { return s.dir }
+
+// The location of rel, a slash-separated path relative to the destination,
+// within the staged tree. Paths that are absolute or leave the destination
+// are an error.
+func (s *Stage) Path(rel string) (string, error) {
+	clean := filepath.Clean(filepath.FromSlash(rel))
+	if filepath.IsAbs(clean) || clean == ".." || strings.HasPrefix(clean, ".."+string(filepath.Separator)) {
+		return "", fmt.Errorf("path outside of %s: %s", s.root, rel)
+	}
+	return filepath.Join(s.dir, clean), nil
+}
+
+// Stage a file at rel, creating any missing parent directories.
+func (s *Stage) WriteFile(rel string, content []byte, perm os.FileMode) error {
+	path, err := s.Path(rel)
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return err
+	}
+	return os.WriteFile(path, content, perm)
+}
+
+// A staged path to rename into the destination.
+type move struct {
+	from, to string
+	policy   Policy // for existing files
+}
+
+// Move the staged tree into the destination. Staged directories that already
+// exist in the destination are merged; existing files are handled according
+// to the Conflict policy. Nothing is moved unless every conflict can be
+// handled. If a move fails the destination is restored. The temporary
+// directory is removed in any case.
+func (s *Stage) Commit() error {
+	if s.done {
+		return fmt.Errorf("stage already finished")
+	}
+	moves, err := s.plan()
+	if err != nil {
+		s.Rollback()
+		return err
+	}
+	for _, m := range moves {
+		if err := s.commit(m); err != nil {
+			s.Rollback()
+			return err
+		}
+	}
+	s.done = true
+	err = os.RemoveAll(s.dir)
+	if s.saved != "" {
+		if rerr := os.RemoveAll(s.saved); err == nil {
+			err = rerr
+		}
+	}
+	return err
+}
+
+func (s *Stage) commit(m move) error {
+	switch m.policy {
+	case Skip:
+		return nil
+	case Merge:
+		ours, err := os.ReadFile(m.to)
+		if err != nil {
+			return err
+		}
+		theirs, err := os.ReadFile(m.from)
+		if err != nil {
+			return err
+		}
+		if bytes.IndexByte(ours, 0) >= 0 || bytes.IndexByte(theirs, 0) >= 0 {
+			return fmt.Errorf("cannot merge binary file: %s", m.to)
+		}
+		labels := diff.Labels{Ours: "existing", Theirs: "generated"}
+		merged, conflicts := diff.Merge2(string(ours), string(theirs), labels)
+		if err := os.WriteFile(m.from, []byte(merged), 0644); err != nil {
+			return err
+		}
+		if conflicts > 0 {
+			rel, err := filepath.Rel(s.root, m.to)
+			if err != nil {
+				return err
+			}
+			s.Conflicts = append(s.Conflicts, filepath.ToSlash(rel))
+		}
+		fallthrough
+	case Overwrite:
+		if err := s.save(m.to); err != nil {
+			return err
+		}
+	case Backup:
+		if err := s.backup(m.to); err != nil {
+			return err
+		}
+	}
+	if err := os.Rename(m.from, m.to); err != nil {
+		return err
+	}
+	s.undo = append(s.undo, func() error { return os.RemoveAll(m.to) })
+	return nil
+}
+
+// Move the existing file path out of the way, so it can be restored.
+func (s *Stage) save(path string) error {
+	if s.saved == "" {
+		saved, err := os.MkdirTemp(s.root, ".gonew-saved-")
+		if err != nil {
+			return err
+		}
+		s.saved = saved
+	}
+	tmp := filepath.Join(s.saved, strconv.Itoa(len(s.undo)))
+	if err := os.Rename(path, tmp); err != nil {
+		return err
+	}
+	s.undo = append(s.undo, func() error { return os.Rename(tmp, path) })
+	return nil
+}
+
+// Rename the existing file path to an unused backup name.
+func (s *Stage) backup(path string) error {
+	bak := path + ".bak"
+	for i := 1; ; i++ {
+		if _, err := os.Lstat(bak); os.IsNotExist(err) {
+			break
+		} else if err != nil {
+			return err
+		}
+		bak = path + ".bak" + strconv.Itoa(i)
+	}
+	if err := os.Rename(path, bak); err != nil {
+		return err
+	}
+	s.undo = append(s.undo, func() error { return os.Rename(bak, path) })
+	return nil
+}
+
+// Determine the renames needed to move the staged tree into place. Whole
+// directories are moved when they do not exist in the destination.
+func (s *Stage) plan() ([]move, error) {
+	var moves []move
+	err := filepath.WalkDir(s.dir, func(path string, d fs.DirEntry, err error) error {
+		if err != nil || path == s.dir {
+			return err
+		}
+		rel, err := filepath.Rel(s.dir, path)
+		if err != nil {
+			return err
+		}
+		dest := filepath.Join(s.root, rel)
+		info, err := os.Lstat(dest)
+		switch {
+		case os.IsNotExist(err):
+			moves = append(moves, move{from: path, to: dest})
+			if d.IsDir() {
+				return filepath.SkipDir
+			}
+			return nil
+		case err != nil:
+			return err
+		case d.IsDir() && info.IsDir():
+			return nil
+		case d.IsDir() || info.IsDir() || s.Conflict == nil:
+			return ErrExist(dest)
+		}
+		policy, err := s.Conflict(filepath.ToSlash(rel))
+		if err != nil {
+			return err
+		}
+		if policy == Fail {
+			return ErrExist(dest)
+		}
+		moves = append(moves, move{path, dest, policy})
+		return nil
+	})
+	return moves, err
+}
+
+// Discard the staged tree and undo the changes of a failed Commit, removing
+// the files it created and restoring the files it replaced. Rollback does
+// nothing after a successful Commit.
+func (s *Stage) Rollback() error {
+	if s.done {
+		return nil
+	}
+	s.done = true
+	var err error
+	for i := len(s.undo) - 1; i >= 0; i-- {
+		if uerr := s.undo[i](); uerr != nil && err == nil {
+			err = uerr
+		}
+	}
+	s.undo = nil
+	for _, dir := range []string{s.dir, s.saved} {
+		if dir == "" {
+			continue
+		}
+		if rerr := os.RemoveAll(dir); rerr != nil && err == nil {
+			err = rerr
+		}
+	}
+	return err
+}
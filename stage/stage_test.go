@@ -0,0 +1,249 @@
+package stage
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func readFile(t *testing.T, path string) string {
+	p, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return string(p)
+}
+
+func entries(t *testing.T, dir string) []string {
+	ents, err := os.ReadDir(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var names []string
+	for _, ent := range ents {
+		names = append(names, ent.Name())
+	}
+	return names
+}
+
+func TestCommit(t *testing.T) {
+	root := t.TempDir()
+	if err := os.MkdirAll(filepath.Join(root, "proj", "keep"), 0755); err != nil {
+		t.Fatal(err)
+	}
+	s, err := New(root)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, path := range []string{"proj/a.go", "proj/sub/b.go", "top.go"} {
+		if err := s.WriteFile(path, []byte(path), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := s.Commit(); err != nil {
+		t.Fatal(err)
+	}
+	for _, path := range []string{"proj/a.go", "proj/sub/b.go", "top.go"} {
+		if content := readFile(t, filepath.Join(root, path)); content != path {
+			t.Errorf("unexpected content of %s: %q", path, content)
+		}
+	}
+	if _, err := os.Stat(s.Dir()); !os.IsNotExist(err) {
+		t.Errorf("staging directory not removed: %v", err)
+	}
+	if err := s.Rollback(); err != nil {
+		t.Errorf("rollback after commit: %v", err)
+	}
+	if _, err := os.Stat(filepath.Join(root, "top.go")); err != nil {
+		t.Errorf("rollback after commit removed files: %v", err)
+	}
+}
+
+func TestCommitConflict(t *testing.T) {
+	root := t.TempDir()
+	if err := os.MkdirAll(filepath.Join(root, "proj"), 0755); err != nil {
+		t.Fatal(err)
+	}
+	existing := filepath.Join(root, "proj", "LICENSE")
+	if err := os.WriteFile(existing, []byte("mine"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	s, err := New(root)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, path := range []string{"proj/a.go", "proj/LICENSE", "other/b.go"} {
+		if err := s.WriteFile(path, []byte(path), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	err = s.Commit()
+	if _, ok := err.(ErrExist); !ok {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if content := readFile(t, existing); content != "mine" {
+		t.Errorf("existing file modified: %q", content)
+	}
+	if names := entries(t, root); len(names) != 1 || names[0] != "proj" {
+		t.Errorf("unexpected files after failed commit: %v", names)
+	}
+	if names := entries(t, filepath.Join(root, "proj")); len(names) != 1 {
+		t.Errorf("unexpected files after failed commit: %v", names)
+	}
+}
+
+func TestRollback(t *testing.T) {
+	root := t.TempDir()
+	s, err := New(root)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := s.WriteFile("proj/a.go", nil, 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := s.Rollback(); err != nil {
+		t.Fatal(err)
+	}
+	if names := entries(t, root); len(names) != 0 {
+		t.Errorf("unexpected files after rollback: %v", names)
+	}
+	if err := s.Commit(); err == nil {
+		t.Errorf("commit after rollback succeeded")
+	}
+}
+
+func TestPath(t *testing.T) {
+	s := &Stage{root: "root", dir: "stage"}
+	for _, rel := range []string{"/etc/passwd", "../x", "a/../../x"} {
+		if _, err := s.Path(rel); err == nil {
+			t.Errorf("path %q allowed", rel)
+		}
+	}
+	if path, err := s.Path("a/./b"); err != nil || path != filepath.Join("stage", "a", "b") {
+		t.Errorf("unexpected path: %q %v", path, err)
+	}
+}
+
+func TestCommitPolicies(t *testing.T) {
+	root := t.TempDir()
+	policies := map[string]Policy{
+		"skip":      Skip,
+		"overwrite": Overwrite,
+		"backup":    Backup,
+		"merge":     Merge,
+	}
+	for name := range policies {
+		if err := os.WriteFile(filepath.Join(root, name), []byte("a\nold\nc\n"), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	s, err := New(root)
+	if err != nil {
+		t.Fatal(err)
+	}
+	s.Conflict = func(rel string) (Policy, error) { return policies[rel], nil }
+	for name := range policies {
+		if err := s.WriteFile(name, []byte("a\nnew\nc\n"), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := s.Commit(); err != nil {
+		t.Fatal(err)
+	}
+	for name, expect := range map[string]string{
+		"skip":       "a\nold\nc\n",
+		"overwrite":  "a\nnew\nc\n",
+		"backup":     "a\nnew\nc\n",
+		"backup.bak": "a\nold\nc\n",
+		"merge":      "a\n<<<<<<< existing\nold\n=======\nnew\n>>>>>>> generated\nc\n",
+	} {
+		if content := readFile(t, filepath.Join(root, name)); content != expect {
+			t.Errorf("unexpected content of %s: %q", name, content)
+		}
+	}
+	if names := entries(t, root); len(names) != 5 {
+		t.Errorf("unexpected files: %v", names)
+	}
+	if len(s.Conflicts) != 1 || s.Conflicts[0] != "merge" {
+		t.Errorf("unexpected conflicts: %v", s.Conflicts)
+	}
+}
+
+func TestCommitPoliciesRollback(t *testing.T) {
+	root := t.TempDir()
+	for _, name := range []string{"overwrite", "backup", "fail"} {
+		if err := os.WriteFile(filepath.Join(root, name), []byte(name), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	s, err := New(root)
+	if err != nil {
+		t.Fatal(err)
+	}
+	s.Conflict = func(rel string) (Policy, error) { return ParsePolicy(rel) }
+	for _, name := range []string{"overwrite", "backup", "new"} {
+		if err := s.WriteFile(name, []byte("generated"), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	// existing files are replaced or backed up, new files created.
+	if err := s.Commit(); err != nil {
+		t.Fatal(err)
+	}
+	s, err = New(root)
+	if err != nil {
+		t.Fatal(err)
+	}
+	s.Conflict = func(rel string) (Policy, error) { return ParsePolicy(rel) }
+	if err := s.WriteFile("fail", nil, 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := s.WriteFile("overwrite", []byte("again"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	// a conflict fails the commit before anything is moved.
+	if _, ok := s.Commit().(ErrExist); !ok {
+		t.Fatalf("expected a conflict error")
+	}
+	if content := readFile(t, filepath.Join(root, "overwrite")); content != "generated" {
+		t.Errorf("failed commit changed a file: %q", content)
+	}
+
+	// undo a partially applied commit.
+	s, err = New(root)
+	if err != nil {
+		t.Fatal(err)
+	}
+	s.Conflict = func(rel string) (Policy, error) { return ParsePolicy(rel) }
+	for _, name := range []string{"overwrite", "backup", "brand-new"} {
+		if err := s.WriteFile(name, []byte("third"), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	moves, err := s.plan()
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, m := range moves {
+		if err := s.commit(m); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := s.Rollback(); err != nil {
+		t.Fatal(err)
+	}
+	for name, expect := range map[string]string{
+		"overwrite":  "generated",
+		"backup":     "generated",
+		"backup.bak": "backup",
+		"new":        "generated",
+		"fail":       "fail",
+	} {
+		if content := readFile(t, filepath.Join(root, name)); content != expect {
+			t.Errorf("unexpected content of %s after rollback: %q", name, content)
+		}
+	}
+	if names := entries(t, root); len(names) != 5 {
+		t.Errorf("unexpected files after rollback: %v", names)
+	}
+}
@@ -0,0 +1,152 @@
+package templates
+
+import (
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"text/template"
+	"text/template/parse"
+)
+
+// A template file of a source.
+type File struct {
+	Path string // Where the file is, for messages
+	Name string // The name of its template
+	Text string
+}
+
+// The template files with extension ext of a file, directory or file system
+// source (possibly in a Namespace), sorted by path. Unlike Source it reads
+// every file without parsing, so that each can be checked on its own.
+func SourceFiles(src interface{}, ext string) ([]*File, error) {
+	var files []*File
+	switch src := src.(type) {
+	case Namespace:
+		return SourceFiles(src.Source, ext)
+	case SourceFile:
+		p, err := os.ReadFile(string(src))
+		if err != nil {
+			return nil, err
+		}
+		files = append(files, &File{string(src), filepath.Base(string(src)), string(p)})
+	case SourceDirectory:
+		dir := string(src)
+		if !isDir(dir) {
+			return nil, fmt.Errorf("not a directory: %s", dir)
+		}
+		err := filepath.Walk(dir, func(path string, info os.FileInfo, err error) error {
+			if err != nil || info.IsDir() || filepath.Ext(path) != ext {
+				return err
+			}
+			p, err := os.ReadFile(path)
+			if err != nil {
+				return err
+			}
+			files = append(files, &File{path, filepath.Base(path), string(p)})
+			return nil
+		})
+		if err != nil {
+			return nil, err
+		}
+	case SourceFS:
+		err := fs.WalkDir(src.FS, ".", func(path string, d fs.DirEntry, err error) error {
+			if err != nil || d.IsDir() || filepath.Ext(path) != ext {
+				return err
+			}
+			p, err := fs.ReadFile(src.FS, path)
+			if err != nil {
+				return err
+			}
+			files = append(files, &File{path, filepath.Base(path), string(p)})
+			return nil
+		})
+		if err != nil {
+			return nil, err
+		}
+	default:
+		return nil, ErrSourceType{src}
+	}
+	sort.Slice(files, func(i, j int) bool { return files[i].Path < files[j].Path })
+	return files, nil
+}
+
+// An error parsing a template File.
+type ParseError struct {
+	Path string
+	Line int // 0 if unknown
+	Msg  string
+}
+
+func (err *ParseError) Error() string {
+	if err.Line == 0 {
+		return err.Path + ": " + err.Msg
+	}
+	return fmt.Sprintf("%s:%d: %s", err.Path, err.Line, err.Msg)
+}
+
+// Like "template: go.pkg.t2:3: unexpected EOF".
+var parseErrorRegexp = regexp.MustCompile(`^template: .*?:(\d+): (.*)$`)
+
+// Parse file on its own, with the functions of a template set having fns
+// added. The result holds the file's template and those it defines. Errors
+// are a *ParseError.
+func Parse(file *File, fns template.FuncMap) (*template.Template, error) {
+	ts := &templates{funcs: fns}
+	t, err := ts.setup().t.New(file.Name).Parse(file.Text)
+	if err != nil {
+		perr := &ParseError{Path: file.Path, Msg: err.Error()}
+		if m := parseErrorRegexp.FindStringSubmatch(err.Error()); m != nil {
+			perr.Line, _ = strconv.Atoi(m[1])
+			perr.Msg = m[2]
+		}
+		return nil, perr
+	}
+	return t, nil
+}
+
+// A use of a template by another.
+type Reference struct {
+	Name   string // The template used, empty for {{extend .}}
+	From   string // The template using it
+	Line   int
+	Extend bool // The reference is {{extend .}}, before rewriting
+}
+
+// The templates used by the templates of t, in the order they appear in
+// their file.
+func References(t *template.Template) []*Reference {
+	var refs []*Reference
+	for _, tt := range t.Templates() {
+		if tt.Tree == nil {
+			continue
+		}
+		walk(tt.Tree.Root, func(node parse.Node) {
+			switch n := node.(type) {
+			case *parse.TemplateNode:
+				refs = append(refs, &Reference{Name: n.Name, From: tt.Name(), Line: n.Line})
+			case *parse.CommandNode:
+				if ident, ok := n.Args[0].(*parse.IdentifierNode); ok && ident.Ident == "extend" {
+					line, _ := tt.Tree.ErrorContext(n)
+					refs = append(refs, &Reference{From: tt.Name(), Line: contextLine(line), Extend: true})
+				}
+			}
+		})
+	}
+	sort.SliceStable(refs, func(i, j int) bool { return refs[i].Line < refs[j].Line })
+	return refs
+}
+
+// The line of a location from parse.Tree.ErrorContext, "name:line:col".
+func contextLine(location string) int {
+	parts := strings.Split(location, ":")
+	if len(parts) < 3 {
+		return 0
+	}
+	line, _ := strconv.Atoi(parts[len(parts)-2])
+	return line
+}
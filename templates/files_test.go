@@ -0,0 +1,39 @@
+package templates
+
+import (
+	"reflect"
+	"testing"
+	"testing/fstest"
+)
+
+func TestParse(t *testing.T) {
+	files, err := SourceFiles(Namespace{"std", SourceFS{fstest.MapFS{
+		"a/b.t2":   {Data: []byte("{{define \"c.t2\"}}c{{end}}\n{{if .}}{{template \"d.t2\" .}}{{end}}\n\n{{extend .}}")},
+		"bad.t2":   {Data: []byte("\n{{end}}")},
+		"notes.md": {Data: []byte("not a template")},
+	}}}, ".t2")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(files) != 2 || files[0].Path != "a/b.t2" || files[0].Name != "b.t2" {
+		t.Fatalf("unexpected files: %v", files)
+	}
+
+	_, err = Parse(files[1], nil)
+	if perr, ok := err.(*ParseError); !ok || perr.Path != "bad.t2" || perr.Line != 2 {
+		t.Errorf("unexpected error: %v", err)
+	}
+
+	tmpl, err := Parse(files[0], nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	refs := References(tmpl.Lookup("b.t2"))
+	expect := []*Reference{
+		{Name: "d.t2", From: "b.t2", Line: 2},
+		{From: "b.t2", Line: 4, Extend: true},
+	}
+	if !reflect.DeepEqual(refs, expect) {
+		t.Errorf("unexpected references: %+v", refs)
+	}
+}
@@ -0,0 +1,176 @@
+package templates
+
+import (
+	"bytes"
+	"fmt"
+	"strconv"
+	"strings"
+	"text/template/parse"
+)
+
+// The namespace of the standard templates.
+const StandardNamespace = "std"
+
+// A source whose templates are named both as usual and qualified by the
+// namespace, as in "std/go.pkg.t2". Unqualified names refer to the template
+// of the namespace added last that defines the name, so later namespaces
+// override earlier ones. Templates of a namespace can render the template
+// they override with {{extend .}}.
+type Namespace struct {
+	Name   string
+	Source interface{}
+}
+
+// Parse the source of ns on its own, then add its templates under their
+// qualified and unqualified names.
+func (ts *templates) sourceNamespace(ns Namespace) error {
+	if ns.Name == "" || strings.ContainsAny(ns.Name, `/\`) {
+		return fmt.Errorf("invalid namespace: %q", ns.Name)
+	}
+	if ts.namespaces[ns.Name] {
+		return fmt.Errorf("namespace %s is already used", ns.Name)
+	}
+	sub := &templates{ext: ts.ext, funcs: ts.funcs}
+	if err := sub.Source(ns.Source); err != nil {
+		return err
+	}
+	if ts.namespaces == nil {
+		ts.namespaces = make(map[string]bool)
+		ts.defs = make(map[string][]string)
+		ts.extends = make(map[string]bool)
+	}
+	ts.namespaces[ns.Name] = true
+	ts.setup()
+	for _, t := range sub.t.Templates() {
+		name := t.Name()
+		if t == sub.t || t.Tree == nil {
+			continue
+		}
+		qualified := ns.Name + "/" + name
+		var base string
+		if defs := ts.defs[name]; len(defs) > 0 {
+			base = defs[len(defs)-1] + "/" + name
+		}
+		if rewriteExtend(t.Tree.Root, base) {
+			if base == "" {
+				return fmt.Errorf("%s: no template to extend", qualified)
+			}
+			ts.extends[qualified] = true
+		}
+		if _, err := ts.t.AddParseTree(qualified, t.Tree); err != nil {
+			return err
+		}
+		if _, err := ts.t.AddParseTree(name, t.Tree); err != nil {
+			return err
+		}
+		ts.defs[name] = append(ts.defs[name], ns.Name)
+	}
+	return nil
+}
+
+// Rewrite the calls {{extend .}} in the tree at node to render the template
+// named base. Returns true if node contains such a call.
+func rewriteExtend(node parse.Node, base string) bool {
+	var found bool
+	walk(node, func(node parse.Node) {
+		cmd, ok := node.(*parse.CommandNode)
+		if !ok {
+			return
+		}
+		if ident, ok := cmd.Args[0].(*parse.IdentifierNode); ok && ident.Ident == "extend" {
+			str := &parse.StringNode{NodeType: parse.NodeString, Pos: ident.Pos, Quoted: strconv.Quote(base), Text: base}
+			cmd.Args = append([]parse.Node{ident, str}, cmd.Args[1:]...)
+			found = true
+		}
+	})
+	return found
+}
+
+// Call fn for node and each node beneath it, parents first.
+func walk(node parse.Node, fn func(parse.Node)) {
+	switch n := node.(type) {
+	case *parse.ListNode:
+		if n == nil {
+			return
+		}
+		fn(n)
+		for _, child := range n.Nodes {
+			walk(child, fn)
+		}
+	case *parse.ActionNode:
+		fn(n)
+		walk(n.Pipe, fn)
+	case *parse.TemplateNode:
+		fn(n)
+		walk(n.Pipe, fn)
+	case *parse.IfNode:
+		fn(n)
+		walkBranch(&n.BranchNode, fn)
+	case *parse.RangeNode:
+		fn(n)
+		walkBranch(&n.BranchNode, fn)
+	case *parse.WithNode:
+		fn(n)
+		walkBranch(&n.BranchNode, fn)
+	case *parse.PipeNode:
+		if n == nil {
+			return
+		}
+		fn(n)
+		for _, cmd := range n.Cmds {
+			walk(cmd, fn)
+		}
+	case *parse.CommandNode:
+		fn(n)
+		for _, arg := range n.Args {
+			walk(arg, fn)
+		}
+	default:
+		fn(n)
+	}
+}
+
+func walkBranch(n *parse.BranchNode, fn func(parse.Node)) {
+	walk(n.Pipe, fn)
+	walk(n.List, fn)
+	walk(n.ElseList, fn)
+}
+
+// Render the template named base, as rewritten by rewriteExtend.
+func (ts *templates) extend(args ...interface{}) (string, error) {
+	base, ok := "", len(args) == 2
+	if ok {
+		base, ok = args[0].(string)
+	}
+	if !ok || base == "" {
+		return "", fmt.Errorf("extend: not in a template overriding another")
+	}
+	buf := new(bytes.Buffer)
+	err := ts.t.ExecuteTemplate(buf, base, args[1])
+	return buf.String(), err
+}
+
+// Record a warning if the unqualified name is defined in several namespaces,
+// unless the template used extends the others.
+func (ts *templates) checkAmbiguous(name string) {
+	defs := ts.defs[name]
+	if len(defs) < 2 || ts.warned[name] {
+		return
+	}
+	used := defs[len(defs)-1]
+	if ts.extends[used+"/"+name] {
+		return
+	}
+	if ts.warned == nil {
+		ts.warned = make(map[string]bool)
+	}
+	ts.warned[name] = true
+	others := make([]string, 0, len(defs)-1)
+	for i := len(defs) - 2; i >= 0; i-- {
+		others = append(others, defs[i]+"/"+name)
+	}
+	ts.warnings = append(ts.warnings, fmt.Sprintf("%s is ambiguous: using %s/%s over %s",
+		name, used, name, strings.Join(others, ", ")))
+}
+
+func (ts *templates) Warnings() []string { return ts.warnings }
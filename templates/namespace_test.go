@@ -0,0 +1,69 @@
+package templates
+
+import (
+	"bytes"
+	"reflect"
+	"strings"
+	"testing"
+	"testing/fstest"
+)
+
+func render(t *testing.T, ts Interface, name string) string {
+	buf := new(bytes.Buffer)
+	if err := ts.Render(buf, name, "data"); err != nil {
+		t.Fatalf("%s: %v", name, err)
+	}
+	return buf.String()
+}
+
+func TestNamespace(t *testing.T) {
+	ts := New(".t2")
+	sources := []Namespace{
+		{"std", SourceFS{fstest.MapFS{
+			"go.pkg.t2":   {Data: []byte(`std pkg {{template "go._head.t2" .}}`)},
+			"go._head.t2": {Data: []byte(`std head`)},
+			"go.cmd.t2":   {Data: []byte(`std cmd`)},
+		}}},
+		{"corp", SourceFS{fstest.MapFS{
+			"go._head.t2": {Data: []byte(`corp head`)},
+			"go.cmd.t2":   {Data: []byte(`corp cmd`)},
+		}}},
+		{"acme", SourceFS{fstest.MapFS{
+			"go.pkg.t2": {Data: []byte(`acme {{extend .}}`)},
+			"go.cmd.t2": {Data: []byte(`acme cmd`)},
+		}}},
+	}
+	for _, ns := range sources {
+		if err := ts.Source(ns); err != nil {
+			t.Fatal(err)
+		}
+	}
+	for name, out := range map[string]string{
+		"go.pkg.t2":       "acme std pkg corp head",
+		"std/go.pkg.t2":   "std pkg corp head",
+		"std/go._head.t2": "std head",
+		"corp/go.cmd.t2":  "corp cmd",
+	} {
+		if s := render(t, ts, name); s != out {
+			t.Errorf("%s: unexpected output %q", name, s)
+		}
+	}
+	if len(ts.Warnings()) != 0 {
+		t.Errorf("unexpected warnings: %v", ts.Warnings())
+	}
+
+	render(t, ts, "go.cmd.t2")
+	render(t, ts, "go.cmd.t2")
+	warnings := []string{"go.cmd.t2 is ambiguous: using acme/go.cmd.t2 over corp/go.cmd.t2, std/go.cmd.t2"}
+	if !reflect.DeepEqual(ts.Warnings(), warnings) {
+		t.Errorf("unexpected warnings: %q", ts.Warnings())
+	}
+
+	if err := ts.Source(Namespace{"corp", SourceTemplate{"x.t2", "x"}}); err == nil {
+		t.Errorf("duplicate namespace accepted")
+	}
+	err := ts.Source(Namespace{"new", SourceTemplate{"new.t2", "{{extend .}}"}})
+	if err == nil || !strings.Contains(err.Error(), "no template to extend") {
+		t.Errorf("unexpected error: %v", err)
+	}
+}
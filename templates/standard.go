@@ -0,0 +1,11 @@
+package templates
+
+import (
+	"embed"
+)
+
+//go:embed README gostd licenses other
+var standard embed.FS
+
+// The standard gonew templates, compiled into the binary.
+func Standard() SourceFS { return SourceFS{standard} }
@@ -0,0 +1,221 @@
+package templates
+
+import (
+	"fmt"
+	"reflect"
+	"text/template/parse"
+)
+
+// A template checked against the type of its data.
+type checkedTemplate struct {
+	name string
+	typ  reflect.Type
+}
+
+func (ts *templates) Strict() {
+	ts.strict = true
+	ts.setup().t.Option("missingkey=error") // shared by every template of the set
+}
+
+// Check the fields used by the template named name, and by the templates it
+// calls, against typ, the type of its data. Every branch is checked, whether
+// or not it would execute. Values of an empty interface type, like the
+// elements of a map[string]interface{}, are not checked.
+func (ts *templates) checkFields(name string, typ reflect.Type) error {
+	key := checkedTemplate{name, typ}
+	if typ == nil || ts.checked[key] {
+		return nil
+	}
+	t := ts.t.Lookup(name)
+	if t == nil || t.Tree == nil {
+		return nil // executing it fails
+	}
+	if ts.checked == nil {
+		ts.checked = make(map[checkedTemplate]bool)
+	}
+	ts.checked[key] = true
+	c := &fieldChecker{ts: ts, tree: t.Tree, root: typ}
+	if err := c.node(t.Tree.Root, typ); err != nil {
+		delete(ts.checked, key)
+		return err
+	}
+	return nil
+}
+
+// Checks the fields of a template tree. A nil type is one that is unknown.
+type fieldChecker struct {
+	ts   *templates
+	tree *parse.Tree
+	root reflect.Type // the type of $
+}
+
+func (c *fieldChecker) node(node parse.Node, dot reflect.Type) error {
+	switch n := node.(type) {
+	case *parse.ListNode:
+		if n == nil {
+			return nil
+		}
+		for _, child := range n.Nodes {
+			if err := c.node(child, dot); err != nil {
+				return err
+			}
+		}
+	case *parse.ActionNode:
+		_, err := c.pipe(n.Pipe, dot)
+		return err
+	case *parse.IfNode:
+		return c.branch(&n.BranchNode, dot, dot)
+	case *parse.WithNode:
+		typ, err := c.pipe(n.Pipe, dot)
+		if err != nil {
+			return err
+		}
+		return c.branch(&n.BranchNode, typ, dot)
+	case *parse.RangeNode:
+		typ, err := c.pipe(n.Pipe, dot)
+		if err != nil {
+			return err
+		}
+		return c.branch(&n.BranchNode, elemType(typ), dot)
+	case *parse.TemplateNode:
+		typ, err := c.pipe(n.Pipe, dot)
+		if err != nil {
+			return err
+		}
+		return c.ts.checkFields(n.Name, typ)
+	}
+	return nil
+}
+
+// The pipeline of n has been checked. The list is checked with dot typ.
+func (c *fieldChecker) branch(n *parse.BranchNode, typ, dot reflect.Type) error {
+	if err := c.node(n.List, typ); err != nil {
+		return err
+	}
+	return c.node(n.ElseList, dot)
+}
+
+// The type of the value of pipe.
+func (c *fieldChecker) pipe(pipe *parse.PipeNode, dot reflect.Type) (reflect.Type, error) {
+	if pipe == nil {
+		return nil, nil
+	}
+	var typ reflect.Type
+	for _, cmd := range pipe.Cmds {
+		var err error
+		if typ, err = c.command(cmd, dot); err != nil {
+			return nil, err
+		}
+	}
+	return typ, nil
+}
+
+func (c *fieldChecker) command(cmd *parse.CommandNode, dot reflect.Type) (reflect.Type, error) {
+	for _, arg := range cmd.Args[1:] {
+		if _, err := c.arg(arg, dot); err != nil {
+			return nil, err
+		}
+	}
+	if ident, ok := cmd.Args[0].(*parse.IdentifierNode); ok {
+		switch ident.Ident {
+		case "gonew", "extend":
+			return reflect.TypeOf(""), nil
+		}
+		return funcResult(c.ts.funcs[ident.Ident]), nil
+	}
+	return c.arg(cmd.Args[0], dot)
+}
+
+func (c *fieldChecker) arg(node parse.Node, dot reflect.Type) (reflect.Type, error) {
+	switch n := node.(type) {
+	case *parse.DotNode:
+		return dot, nil
+	case *parse.FieldNode:
+		return c.fields(n, dot, n.Ident)
+	case *parse.VariableNode:
+		if n.Ident[0] == "$" {
+			return c.fields(n, c.root, n.Ident[1:])
+		}
+	case *parse.ChainNode:
+		typ, err := c.arg(n.Node, dot)
+		if err != nil {
+			return nil, err
+		}
+		return c.fields(n, typ, n.Field)
+	case *parse.PipeNode:
+		return c.pipe(n, dot)
+	}
+	return nil, nil
+}
+
+// The type of fields idents of a value of type typ.
+func (c *fieldChecker) fields(node parse.Node, typ reflect.Type, idents []string) (reflect.Type, error) {
+	for _, ident := range idents {
+		if typ == nil {
+			return nil, nil
+		}
+		next, ok := fieldType(typ, ident)
+		if !ok {
+			location, context := c.tree.ErrorContext(node)
+			return nil, fmt.Errorf("template: %s: executing %q at <%s>: can't evaluate field %s in type %s",
+				location, c.tree.Name, context, ident, typ)
+		}
+		typ = next
+	}
+	return typ, nil
+}
+
+// The type of the field or method name of typ, as found when executing
+// templates. The type is nil if it can't be known.
+func fieldType(typ reflect.Type, name string) (reflect.Type, bool) {
+	if typ.Kind() == reflect.Interface {
+		if m, ok := typ.MethodByName(name); ok {
+			return funcResult(m.Type), true
+		}
+		return nil, typ.NumMethod() == 0
+	}
+	ptr := typ
+	if ptr.Kind() != reflect.Ptr {
+		ptr = reflect.PtrTo(typ)
+	}
+	if m, ok := ptr.MethodByName(name); ok {
+		return funcResult(m.Type), true
+	}
+	for typ.Kind() == reflect.Ptr {
+		typ = typ.Elem()
+	}
+	switch typ.Kind() {
+	case reflect.Struct:
+		if f, ok := typ.FieldByName(name); ok && f.PkgPath == "" {
+			return f.Type, true
+		}
+	case reflect.Map:
+		// missing keys are an error when executing.
+		return typ.Elem(), typ.Key().Kind() == reflect.String
+	}
+	return nil, false
+}
+
+// The type of the first result of fn, a function or function type.
+func funcResult(fn interface{}) reflect.Type {
+	typ, ok := fn.(reflect.Type)
+	if !ok && fn != nil {
+		typ = reflect.TypeOf(fn)
+	}
+	if typ == nil || typ.Kind() != reflect.Func || typ.NumOut() == 0 {
+		return nil
+	}
+	return typ.Out(0)
+}
+
+// The type of the elements ranged over in a value of type typ.
+func elemType(typ reflect.Type) reflect.Type {
+	if typ == nil {
+		return nil
+	}
+	switch typ.Kind() {
+	case reflect.Array, reflect.Slice, reflect.Map, reflect.Chan:
+		return typ.Elem()
+	}
+	return nil
+}
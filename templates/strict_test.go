@@ -0,0 +1,53 @@
+package templates
+
+import (
+	"io/ioutil"
+	"strings"
+	"testing"
+)
+
+type strictUser struct{ Name string }
+
+type strictData struct {
+	User  *strictUser
+	Users []strictUser
+	Vars  map[string]interface{}
+}
+
+func (d strictData) Owner() strictUser { return *d.User }
+
+func TestStrict(t *testing.T) {
+	data := strictData{User: &strictUser{"gopher"}, Vars: map[string]interface{}{"x": 1}}
+	for _, test := range []struct {
+		text   string
+		err    string // empty if strict rendering succeeds
+		strict bool   // the error is only strict
+	}{
+		{text: `{{.User.Name}} {{.Owner.Name}} {{.Vars.x}}`, err: ""},
+		{text: `{{range .Users}}{{.Name}}{{end}}{{with .User}}{{.Name}}{{end}}`, err: ""},
+		{text: `{{define "u"}}{{.Name}}{{end}}{{template "u" .User}}{{$.User.Name}}`, err: ""},
+		{text: `{{if false}}{{.User.Nmae}}{{end}}`, err: "can't evaluate field Nmae", strict: true},
+		{text: `{{range .Users}}{{.Nmae}}{{end}}`, err: "can't evaluate field Nmae", strict: true},
+		{text: `{{define "u"}}{{.Nmae}}{{end}}{{if false}}{{template "u" .Owner}}{{end}}`, err: "can't evaluate field Nmae", strict: true},
+		{text: `{{.Vars.y}}`, err: `no entry for key "y"`, strict: true},
+		{text: `{{.User.Nmae}}`, err: "can't evaluate field Nmae"},
+	} {
+		for _, strict := range []bool{false, true} {
+			ts := New(".t2")
+			if strict {
+				ts.Strict()
+			}
+			if err := ts.Source(SourceTemplate{"t.t2", test.text}); err != nil {
+				t.Fatal(err)
+			}
+			err := ts.Render(ioutil.Discard, "t.t2", data)
+			if test.err == "" || (test.strict && !strict) {
+				if err != nil {
+					t.Errorf("%s (strict %v): %v", test.text, strict, err)
+				}
+			} else if err == nil || !strings.Contains(err.Error(), test.err) {
+				t.Errorf("%s (strict %v): unexpected error: %v", test.text, strict, err)
+			}
+		}
+	}
+}
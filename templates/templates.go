@@ -14,8 +14,10 @@ package templates
 import (
 	"fmt"
 	"io"
+	"io/fs"
 	"os"
 	"path/filepath"
+	"reflect"
 	"text/template"
 )
 
@@ -23,6 +25,9 @@ type SourceTemplate struct{ Name, Text string }
 type SourceFile string
 type SourceDirectory string
 
+// A file system (e.g. an embed.FS) searched recursively for templates.
+type SourceFS struct{ FS fs.FS }
+
 type ErrSourceType struct{ v interface{} }
 type ErrNoTemplate string
 
@@ -34,12 +39,25 @@ type Interface interface {
 	Render(io.Writer, string, interface{}) error // Render a named template.
 	Source(interface{}) error                    // Add a template source.
 	Funcs(template.FuncMap) error                // Add a set of functions.
+	Warnings() []string                          // Problems found rendering templates, like ambiguous names.
+	Strict()                                     // Fail on missing map keys and fields the data lacks.
+	Clone() (Interface, error)                   // A copy whose templates and functions can change independently.
 }
 
 // The straight-forward implementation of Interface.
 type templates struct {
-	t   *template.Template
-	ext string
+	t     *template.Template
+	ext   string
+	funcs template.FuncMap // added with Funcs, for parsing namespaces
+
+	namespaces map[string]bool
+	defs       map[string][]string // namespaces defining each name, lowest precedence first
+	extends    map[string]bool     // qualified names of templates using extend
+	warned     map[string]bool
+	warnings   []string
+
+	strict  bool
+	checked map[checkedTemplate]bool // templates with fields checked, for strict rendering
 }
 
 // Create a new template set that recognizes ext as a template file extension.
@@ -49,18 +67,28 @@ func (ts *templates) Render(out io.Writer, name string, environment interface{})
 	if ts.t == nil {
 		return ErrNoTemplate(name)
 	}
+	ts.checkAmbiguous(name)
+	if ts.strict {
+		if err := ts.checkFields(name, reflect.TypeOf(environment)); err != nil {
+			return err
+		}
+	}
 	return ts.t.ExecuteTemplate(out, name, environment)
 }
 
 func (ts *templates) setup() *templates {
 	if ts.t == nil {
-		fns := template.FuncMap{"gonew": func() string { return "gonew v2" }}
-		ts.t = template.Must(template.New("gonew").Funcs(fns).Parse("{{gonew}}"))
+		fns := template.FuncMap{
+			"gonew":  func() string { return "gonew v2" },
+			"extend": ts.extend,
+		}
+		ts.t = template.Must(template.New("gonew").Funcs(fns).Funcs(ts.funcs).Parse("{{gonew}}"))
 	}
 	return ts
 }
 
 func (ts *templates) Source(src interface{}) (err error) {
+	ts.checked = nil
 	switch src.(type) {
 	case SourceTemplate:
 		_, err = ts.setup().t.
@@ -81,6 +109,27 @@ func (ts *templates) Source(src interface{}) (err error) {
 			return nil
 		})
 		_, err = ts.setup().t.ParseFiles(paths...)
+	case SourceFS:
+		fsys := src.(SourceFS).FS
+		var paths []string
+		err = fs.WalkDir(fsys, ".", func(path string, d fs.DirEntry, err error) error {
+			if err != nil {
+				return err
+			}
+			if !d.IsDir() && filepath.Ext(path) == ts.ext {
+				paths = append(paths, path)
+			}
+			return nil
+		})
+		if err != nil {
+			return
+		}
+		if len(paths) == 0 {
+			return fmt.Errorf("no templates found")
+		}
+		_, err = ts.setup().t.ParseFS(fsys, paths...)
+	case Namespace:
+		err = ts.sourceNamespace(src.(Namespace))
 	case *template.Template:
 		t := src.(*template.Template)
 		_, err = ts.setup().t.AddParseTree(t.Name(), t.Tree)
@@ -90,8 +139,59 @@ func (ts *templates) Source(src interface{}) (err error) {
 	return
 }
 
+func (ts *templates) Clone() (Interface, error) {
+	t, err := ts.setup().t.Clone()
+	if err != nil {
+		return nil, err
+	}
+	c := &templates{
+		t:          t,
+		ext:        ts.ext,
+		funcs:      copyFuncs(ts.funcs),
+		namespaces: copyBools(ts.namespaces),
+		defs:       make(map[string][]string, len(ts.defs)),
+		extends:    copyBools(ts.extends),
+		warned:     copyBools(ts.warned),
+		warnings:   append([]string(nil), ts.warnings...),
+		strict:     ts.strict,
+	}
+	for name, defs := range ts.defs {
+		c.defs[name] = append([]string(nil), defs...)
+	}
+	t.Funcs(template.FuncMap{"extend": c.extend})
+	return c, nil
+}
+
+func copyFuncs(fns template.FuncMap) template.FuncMap {
+	if fns == nil {
+		return nil
+	}
+	c := make(template.FuncMap, len(fns))
+	for name, fn := range fns {
+		c[name] = fn
+	}
+	return c
+}
+
+func copyBools(m map[string]bool) map[string]bool {
+	if m == nil {
+		return nil
+	}
+	c := make(map[string]bool, len(m))
+	for k, v := range m {
+		c[k] = v
+	}
+	return c
+}
+
 func (ts *templates) Funcs(fns template.FuncMap) error {
 	ts.setup().t.Funcs(fns)
+	if ts.funcs == nil {
+		ts.funcs = make(template.FuncMap, len(fns))
+	}
+	for name, fn := range fns {
+		ts.funcs[name] = fn
+	}
 	return nil
 }
 
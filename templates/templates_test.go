@@ -7,11 +7,96 @@ package templates
  */
 
 import (
-    "testing"
+	"bytes"
+	"io/fs"
+	"testing"
+	"testing/fstest"
+	"text/template"
 )
 
-
 func TestTemplates(t *testing.T) {
+	ts := New(".t2")
+	if err := ts.Funcs(template.FuncMap{"shout": func(s string) string { return s + "!" }}); err != nil {
+		t.Fatal(err)
+	}
+	err := ts.Source(SourceFS{fstest.MapFS{
+		"a.t2":      {Data: []byte(`a {{shout .}} {{template "b.t2" .}}`)},
+		"dir/b.t2":  {Data: []byte(`b`)},
+		"notes.txt": {Data: []byte(`{{not a template`)},
+	}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := ts.Source(SourceTemplate{"b.t2", "B"}); err != nil {
+		t.Fatal(err)
+	}
+	buf := new(bytes.Buffer)
+	if err := ts.Render(buf, "a.t2", "hi"); err != nil {
+		t.Fatal(err)
+	}
+	if buf.String() != "a hi! B" {
+		t.Errorf("unexpected output: %q", buf)
+	}
+	if err := ts.Render(buf, "missing.t2", nil); err == nil {
+		t.Errorf("missing template rendered")
+	}
+	if err := ts.Source(42); err == nil {
+		t.Errorf("unexpected source accepted")
+	}
+	if err := ts.Source(SourceDirectory("does-not-exist")); err == nil {
+		t.Errorf("missing directory accepted")
+	}
+}
 
+func TestClone(t *testing.T) {
+	ts := New(".t2")
+	if err := ts.Funcs(template.FuncMap{"word": func() string { return "a" }}); err != nil {
+		t.Fatal(err)
+	}
+	if err := ts.Source(SourceTemplate{"w.t2", "{{word}}"}); err != nil {
+		t.Fatal(err)
+	}
+	c, err := ts.Clone()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := c.Funcs(template.FuncMap{"word": func() string { return "b" }}); err != nil {
+		t.Fatal(err)
+	}
+	if err := c.Source(SourceTemplate{"x.t2", "x"}); err != nil {
+		t.Fatal(err)
+	}
+	buf := new(bytes.Buffer)
+	for _, set := range []Interface{ts, c, ts} {
+		if err := set.Render(buf, "w.t2", nil); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if buf.String() != "aba" {
+		t.Errorf("unexpected output: %q", buf)
+	}
+	if err := ts.Render(buf, "x.t2", nil); err == nil {
+		t.Errorf("template added to the clone rendered by the original")
+	}
 }
 
+func TestStandard(t *testing.T) {
+	for _, path := range []string{"gostd/go.pkg.t2", "licenses/license.newbsd.t2", "other/travis.yml.t2"} {
+		if _, err := fs.Stat(Standard().FS, path); err != nil {
+			t.Error(err)
+		}
+	}
+	// the functions gonew adds, which parsing only needs to exist.
+	ts := New(".t2")
+	stub := func(...interface{}) string { return "" }
+	fns := template.FuncMap{}
+	for _, name := range []string{"name", "email", "year", "time", "date", "import", "equal"} {
+		fns[name] = stub
+	}
+	if err := ts.Funcs(fns); err != nil {
+		t.Fatal(err)
+	}
+	if err := ts.Source(Standard()); err != nil {
+		t.Error(err)
+	}
+}
@@ -7,11 +7,31 @@ package templates
  */
 
 import (
-    "testing"
+	"bytes"
+	"testing"
 )
 
-
 func TestUtils(t *testing.T) {
-
+	ts := New(".t2")
+	if err := ts.Source(SourceTemplate{"greet.t2", "hello {{.}}"}); err != nil {
+		t.Fatal(err)
+	}
+	env := Env("gopher")
+	buf := new(bytes.Buffer)
+	if err := env.Render(buf, ts, "greet.t2", "greet.t2"); err != nil {
+		t.Fatal(err)
+	}
+	if buf.String() != "hello gopherhello gopher" {
+		t.Errorf("unexpected output: %q", buf)
+	}
+	s, err := env.RenderTextAsString(ts, "text_", `{{template "greet.t2" .}}!`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if s != "hello gopher!" {
+		t.Errorf("unexpected output: %q", s)
+	}
+	if _, err := env.RenderTextAsString(ts, "text_", "{{"); err == nil {
+		t.Errorf("invalid template rendered")
+	}
 }
-